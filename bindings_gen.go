@@ -0,0 +1,308 @@
+// Code generated by bindgen; DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	packages["fmt"] = map[Symbol]any{
+		Symbol("fmt.Errorf"):   gofunc(fmt.Errorf),
+		Symbol("fmt.Fprint"):   gofunc(fmt.Fprint),
+		Symbol("fmt.Fprintf"):  gofunc(fmt.Fprintf),
+		Symbol("fmt.Fprintln"): gofunc(fmt.Fprintln),
+		Symbol("fmt.Fscan"):    gofunc(fmt.Fscan),
+		Symbol("fmt.Fscanf"):   gofunc(fmt.Fscanf),
+		Symbol("fmt.Fscanln"):  gofunc(fmt.Fscanln),
+		Symbol("fmt.Print"):    gofunc(fmt.Print),
+		Symbol("fmt.Printf"):   gofunc(fmt.Printf),
+		Symbol("fmt.Println"):  gofunc(fmt.Println),
+		Symbol("fmt.Scan"):     gofunc(fmt.Scan),
+		Symbol("fmt.Scanf"):    gofunc(fmt.Scanf),
+		Symbol("fmt.Scanln"):   gofunc(fmt.Scanln),
+		Symbol("fmt.Sprint"):   gofunc(fmt.Sprint),
+		Symbol("fmt.Sprintf"):  gofunc(fmt.Sprintf),
+		Symbol("fmt.Sprintln"): gofunc(fmt.Sprintln),
+		Symbol("fmt.Sscan"):    gofunc(fmt.Sscan),
+		Symbol("fmt.Sscanf"):   gofunc(fmt.Sscanf),
+		Symbol("fmt.Sscanln"):  gofunc(fmt.Sscanln),
+	}
+	packages["math"] = map[Symbol]any{
+		Symbol("math.Abs"):                    gofunc(math.Abs),
+		Symbol("math.Acos"):                   gofunc(math.Acos),
+		Symbol("math.Acosh"):                  gofunc(math.Acosh),
+		Symbol("math.Asin"):                   gofunc(math.Asin),
+		Symbol("math.Asinh"):                  gofunc(math.Asinh),
+		Symbol("math.Atan"):                   gofunc(math.Atan),
+		Symbol("math.Atan2"):                  gofunc(math.Atan2),
+		Symbol("math.Atanh"):                  gofunc(math.Atanh),
+		Symbol("math.Cbrt"):                   gofunc(math.Cbrt),
+		Symbol("math.Ceil"):                   gofunc(math.Ceil),
+		Symbol("math.Copysign"):               gofunc(math.Copysign),
+		Symbol("math.Cos"):                    gofunc(math.Cos),
+		Symbol("math.Cosh"):                   gofunc(math.Cosh),
+		Symbol("math.Dim"):                    gofunc(math.Dim),
+		Symbol("math.E"):                      float64(math.E),
+		Symbol("math.Erf"):                    gofunc(math.Erf),
+		Symbol("math.Erfc"):                   gofunc(math.Erfc),
+		Symbol("math.Erfcinv"):                gofunc(math.Erfcinv),
+		Symbol("math.Erfinv"):                 gofunc(math.Erfinv),
+		Symbol("math.Exp"):                    gofunc(math.Exp),
+		Symbol("math.Exp2"):                   gofunc(math.Exp2),
+		Symbol("math.Expm1"):                  gofunc(math.Expm1),
+		Symbol("math.FMA"):                    gofunc(math.FMA),
+		Symbol("math.Float32bits"):            gofunc(math.Float32bits),
+		Symbol("math.Float32frombits"):        gofunc(math.Float32frombits),
+		Symbol("math.Float64bits"):            gofunc(math.Float64bits),
+		Symbol("math.Float64frombits"):        gofunc(math.Float64frombits),
+		Symbol("math.Floor"):                  gofunc(math.Floor),
+		Symbol("math.Frexp"):                  gofunc(math.Frexp),
+		Symbol("math.Gamma"):                  gofunc(math.Gamma),
+		Symbol("math.Hypot"):                  gofunc(math.Hypot),
+		Symbol("math.Ilogb"):                  gofunc(math.Ilogb),
+		Symbol("math.Inf"):                    gofunc(math.Inf),
+		Symbol("math.IsInf"):                  gofunc(math.IsInf),
+		Symbol("math.IsNaN"):                  gofunc(math.IsNaN),
+		Symbol("math.J0"):                     gofunc(math.J0),
+		Symbol("math.J1"):                     gofunc(math.J1),
+		Symbol("math.Jn"):                     gofunc(math.Jn),
+		Symbol("math.Ldexp"):                  gofunc(math.Ldexp),
+		Symbol("math.Lgamma"):                 gofunc(math.Lgamma),
+		Symbol("math.Ln10"):                   float64(math.Ln10),
+		Symbol("math.Ln2"):                    float64(math.Ln2),
+		Symbol("math.Log"):                    gofunc(math.Log),
+		Symbol("math.Log10"):                  gofunc(math.Log10),
+		Symbol("math.Log10E"):                 float64(math.Log10E),
+		Symbol("math.Log1p"):                  gofunc(math.Log1p),
+		Symbol("math.Log2"):                   gofunc(math.Log2),
+		Symbol("math.Log2E"):                  float64(math.Log2E),
+		Symbol("math.Logb"):                   gofunc(math.Logb),
+		Symbol("math.Max"):                    gofunc(math.Max),
+		Symbol("math.MaxFloat32"):             float64(math.MaxFloat32),
+		Symbol("math.MaxFloat64"):             float64(math.MaxFloat64),
+		Symbol("math.MaxInt"):                 int64(math.MaxInt),
+		Symbol("math.MaxInt16"):               int(math.MaxInt16),
+		Symbol("math.MaxInt32"):               int(math.MaxInt32),
+		Symbol("math.MaxInt64"):               int64(math.MaxInt64),
+		Symbol("math.MaxInt8"):                int(math.MaxInt8),
+		Symbol("math.MaxUint"):                uint64(math.MaxUint),
+		Symbol("math.MaxUint16"):              int(math.MaxUint16),
+		Symbol("math.MaxUint32"):              int64(math.MaxUint32),
+		Symbol("math.MaxUint64"):              uint64(math.MaxUint64),
+		Symbol("math.MaxUint8"):               int(math.MaxUint8),
+		Symbol("math.Min"):                    gofunc(math.Min),
+		Symbol("math.MinInt"):                 int64(math.MinInt),
+		Symbol("math.MinInt16"):               int(math.MinInt16),
+		Symbol("math.MinInt32"):               int(math.MinInt32),
+		Symbol("math.MinInt64"):               int64(math.MinInt64),
+		Symbol("math.MinInt8"):                int(math.MinInt8),
+		Symbol("math.Mod"):                    gofunc(math.Mod),
+		Symbol("math.Modf"):                   gofunc(math.Modf),
+		Symbol("math.NaN"):                    gofunc(math.NaN),
+		Symbol("math.Nextafter"):              gofunc(math.Nextafter),
+		Symbol("math.Nextafter32"):            gofunc(math.Nextafter32),
+		Symbol("math.Phi"):                    float64(math.Phi),
+		Symbol("math.Pi"):                     float64(math.Pi),
+		Symbol("math.Pow"):                    gofunc(math.Pow),
+		Symbol("math.Pow10"):                  gofunc(math.Pow10),
+		Symbol("math.Remainder"):              gofunc(math.Remainder),
+		Symbol("math.Round"):                  gofunc(math.Round),
+		Symbol("math.RoundToEven"):            gofunc(math.RoundToEven),
+		Symbol("math.Signbit"):                gofunc(math.Signbit),
+		Symbol("math.Sin"):                    gofunc(math.Sin),
+		Symbol("math.Sincos"):                 gofunc(math.Sincos),
+		Symbol("math.Sinh"):                   gofunc(math.Sinh),
+		Symbol("math.SmallestNonzeroFloat32"): float64(math.SmallestNonzeroFloat32),
+		Symbol("math.SmallestNonzeroFloat64"): float64(math.SmallestNonzeroFloat64),
+		Symbol("math.Sqrt"):                   gofunc(math.Sqrt),
+		Symbol("math.Sqrt2"):                  float64(math.Sqrt2),
+		Symbol("math.SqrtE"):                  float64(math.SqrtE),
+		Symbol("math.SqrtPhi"):                float64(math.SqrtPhi),
+		Symbol("math.SqrtPi"):                 float64(math.SqrtPi),
+		Symbol("math.Tan"):                    gofunc(math.Tan),
+		Symbol("math.Tanh"):                   gofunc(math.Tanh),
+		Symbol("math.Trunc"):                  gofunc(math.Trunc),
+		Symbol("math.Y0"):                     gofunc(math.Y0),
+		Symbol("math.Y1"):                     gofunc(math.Y1),
+		Symbol("math.Yn"):                     gofunc(math.Yn),
+	}
+	packages["path/filepath"] = map[Symbol]any{
+		Symbol("filepath.Abs"):           gofunc(filepath.Abs),
+		Symbol("filepath.Base"):          gofunc(filepath.Base),
+		Symbol("filepath.Clean"):         gofunc(filepath.Clean),
+		Symbol("filepath.Dir"):           gofunc(filepath.Dir),
+		Symbol("filepath.EvalSymlinks"):  gofunc(filepath.EvalSymlinks),
+		Symbol("filepath.Ext"):           gofunc(filepath.Ext),
+		Symbol("filepath.FromSlash"):     gofunc(filepath.FromSlash),
+		Symbol("filepath.Glob"):          gofunc(filepath.Glob),
+		Symbol("filepath.HasPrefix"):     gofunc(filepath.HasPrefix),
+		Symbol("filepath.IsAbs"):         gofunc(filepath.IsAbs),
+		Symbol("filepath.Join"):          gofunc(filepath.Join),
+		Symbol("filepath.ListSeparator"): rune(filepath.ListSeparator),
+		Symbol("filepath.Match"):         gofunc(filepath.Match),
+		Symbol("filepath.Rel"):           gofunc(filepath.Rel),
+		Symbol("filepath.Separator"):     rune(filepath.Separator),
+		Symbol("filepath.Split"):         gofunc(filepath.Split),
+		Symbol("filepath.SplitList"):     gofunc(filepath.SplitList),
+		Symbol("filepath.ToSlash"):       gofunc(filepath.ToSlash),
+		Symbol("filepath.VolumeName"):    gofunc(filepath.VolumeName),
+		Symbol("filepath.Walk"):          gofunc(filepath.Walk),
+		Symbol("filepath.WalkDir"):       gofunc(filepath.WalkDir),
+	}
+	packages["strconv"] = map[Symbol]any{
+		Symbol("strconv.AppendBool"):               gofunc(strconv.AppendBool),
+		Symbol("strconv.AppendFloat"):              gofunc(strconv.AppendFloat),
+		Symbol("strconv.AppendInt"):                gofunc(strconv.AppendInt),
+		Symbol("strconv.AppendQuote"):              gofunc(strconv.AppendQuote),
+		Symbol("strconv.AppendQuoteRune"):          gofunc(strconv.AppendQuoteRune),
+		Symbol("strconv.AppendQuoteRuneToASCII"):   gofunc(strconv.AppendQuoteRuneToASCII),
+		Symbol("strconv.AppendQuoteRuneToGraphic"): gofunc(strconv.AppendQuoteRuneToGraphic),
+		Symbol("strconv.AppendQuoteToASCII"):       gofunc(strconv.AppendQuoteToASCII),
+		Symbol("strconv.AppendQuoteToGraphic"):     gofunc(strconv.AppendQuoteToGraphic),
+		Symbol("strconv.AppendUint"):               gofunc(strconv.AppendUint),
+		Symbol("strconv.Atoi"):                     gofunc(strconv.Atoi),
+		Symbol("strconv.CanBackquote"):             gofunc(strconv.CanBackquote),
+		Symbol("strconv.FormatBool"):               gofunc(strconv.FormatBool),
+		Symbol("strconv.FormatComplex"):            gofunc(strconv.FormatComplex),
+		Symbol("strconv.FormatFloat"):              gofunc(strconv.FormatFloat),
+		Symbol("strconv.FormatInt"):                gofunc(strconv.FormatInt),
+		Symbol("strconv.FormatUint"):               gofunc(strconv.FormatUint),
+		Symbol("strconv.IntSize"):                  int(strconv.IntSize),
+		Symbol("strconv.IsGraphic"):                gofunc(strconv.IsGraphic),
+		Symbol("strconv.IsPrint"):                  gofunc(strconv.IsPrint),
+		Symbol("strconv.Itoa"):                     gofunc(strconv.Itoa),
+		Symbol("strconv.ParseBool"):                gofunc(strconv.ParseBool),
+		Symbol("strconv.ParseComplex"):             gofunc(strconv.ParseComplex),
+		Symbol("strconv.ParseFloat"):               gofunc(strconv.ParseFloat),
+		Symbol("strconv.ParseInt"):                 gofunc(strconv.ParseInt),
+		Symbol("strconv.ParseUint"):                gofunc(strconv.ParseUint),
+		Symbol("strconv.Quote"):                    gofunc(strconv.Quote),
+		Symbol("strconv.QuoteRune"):                gofunc(strconv.QuoteRune),
+		Symbol("strconv.QuoteRuneToASCII"):         gofunc(strconv.QuoteRuneToASCII),
+		Symbol("strconv.QuoteRuneToGraphic"):       gofunc(strconv.QuoteRuneToGraphic),
+		Symbol("strconv.QuoteToASCII"):             gofunc(strconv.QuoteToASCII),
+		Symbol("strconv.QuoteToGraphic"):           gofunc(strconv.QuoteToGraphic),
+		Symbol("strconv.QuotedPrefix"):             gofunc(strconv.QuotedPrefix),
+		Symbol("strconv.Unquote"):                  gofunc(strconv.Unquote),
+		Symbol("strconv.UnquoteChar"):              gofunc(strconv.UnquoteChar),
+	}
+	packages["strings"] = map[Symbol]any{
+		Symbol("strings.Clone"):          gofunc(strings.Clone),
+		Symbol("strings.Compare"):        gofunc(strings.Compare),
+		Symbol("strings.Contains"):       gofunc(strings.Contains),
+		Symbol("strings.ContainsAny"):    gofunc(strings.ContainsAny),
+		Symbol("strings.ContainsRune"):   gofunc(strings.ContainsRune),
+		Symbol("strings.Count"):          gofunc(strings.Count),
+		Symbol("strings.Cut"):            gofunc(strings.Cut),
+		Symbol("strings.EqualFold"):      gofunc(strings.EqualFold),
+		Symbol("strings.Fields"):         gofunc(strings.Fields),
+		Symbol("strings.FieldsFunc"):     gofunc(strings.FieldsFunc),
+		Symbol("strings.HasPrefix"):      gofunc(strings.HasPrefix),
+		Symbol("strings.HasSuffix"):      gofunc(strings.HasSuffix),
+		Symbol("strings.Index"):          gofunc(strings.Index),
+		Symbol("strings.IndexAny"):       gofunc(strings.IndexAny),
+		Symbol("strings.IndexByte"):      gofunc(strings.IndexByte),
+		Symbol("strings.IndexFunc"):      gofunc(strings.IndexFunc),
+		Symbol("strings.IndexRune"):      gofunc(strings.IndexRune),
+		Symbol("strings.Join"):           gofunc(strings.Join),
+		Symbol("strings.LastIndex"):      gofunc(strings.LastIndex),
+		Symbol("strings.LastIndexAny"):   gofunc(strings.LastIndexAny),
+		Symbol("strings.LastIndexByte"):  gofunc(strings.LastIndexByte),
+		Symbol("strings.LastIndexFunc"):  gofunc(strings.LastIndexFunc),
+		Symbol("strings.Map"):            gofunc(strings.Map),
+		Symbol("strings.NewReader"):      gofunc(strings.NewReader),
+		Symbol("strings.NewReplacer"):    gofunc(strings.NewReplacer),
+		Symbol("strings.Repeat"):         gofunc(strings.Repeat),
+		Symbol("strings.Replace"):        gofunc(strings.Replace),
+		Symbol("strings.ReplaceAll"):     gofunc(strings.ReplaceAll),
+		Symbol("strings.Split"):          gofunc(strings.Split),
+		Symbol("strings.SplitAfter"):     gofunc(strings.SplitAfter),
+		Symbol("strings.SplitAfterN"):    gofunc(strings.SplitAfterN),
+		Symbol("strings.SplitN"):         gofunc(strings.SplitN),
+		Symbol("strings.Title"):          gofunc(strings.Title),
+		Symbol("strings.ToLower"):        gofunc(strings.ToLower),
+		Symbol("strings.ToLowerSpecial"): gofunc(strings.ToLowerSpecial),
+		Symbol("strings.ToTitle"):        gofunc(strings.ToTitle),
+		Symbol("strings.ToTitleSpecial"): gofunc(strings.ToTitleSpecial),
+		Symbol("strings.ToUpper"):        gofunc(strings.ToUpper),
+		Symbol("strings.ToUpperSpecial"): gofunc(strings.ToUpperSpecial),
+		Symbol("strings.ToValidUTF8"):    gofunc(strings.ToValidUTF8),
+		Symbol("strings.Trim"):           gofunc(strings.Trim),
+		Symbol("strings.TrimFunc"):       gofunc(strings.TrimFunc),
+		Symbol("strings.TrimLeft"):       gofunc(strings.TrimLeft),
+		Symbol("strings.TrimLeftFunc"):   gofunc(strings.TrimLeftFunc),
+		Symbol("strings.TrimPrefix"):     gofunc(strings.TrimPrefix),
+		Symbol("strings.TrimRight"):      gofunc(strings.TrimRight),
+		Symbol("strings.TrimRightFunc"):  gofunc(strings.TrimRightFunc),
+		Symbol("strings.TrimSpace"):      gofunc(strings.TrimSpace),
+		Symbol("strings.TrimSuffix"):     gofunc(strings.TrimSuffix),
+	}
+	packages["time"] = map[Symbol]any{
+		Symbol("time.ANSIC"):                  time.ANSIC,
+		Symbol("time.After"):                  gofunc(time.After),
+		Symbol("time.AfterFunc"):              gofunc(time.AfterFunc),
+		Symbol("time.April"):                  time.April,
+		Symbol("time.August"):                 time.August,
+		Symbol("time.Date"):                   gofunc(time.Date),
+		Symbol("time.December"):               time.December,
+		Symbol("time.February"):               time.February,
+		Symbol("time.FixedZone"):              gofunc(time.FixedZone),
+		Symbol("time.Friday"):                 time.Friday,
+		Symbol("time.Hour"):                   time.Hour,
+		Symbol("time.January"):                time.January,
+		Symbol("time.July"):                   time.July,
+		Symbol("time.June"):                   time.June,
+		Symbol("time.Kitchen"):                time.Kitchen,
+		Symbol("time.Layout"):                 time.Layout,
+		Symbol("time.LoadLocation"):           gofunc(time.LoadLocation),
+		Symbol("time.LoadLocationFromTZData"): gofunc(time.LoadLocationFromTZData),
+		Symbol("time.March"):                  time.March,
+		Symbol("time.May"):                    time.May,
+		Symbol("time.Microsecond"):            time.Microsecond,
+		Symbol("time.Millisecond"):            time.Millisecond,
+		Symbol("time.Minute"):                 time.Minute,
+		Symbol("time.Monday"):                 time.Monday,
+		Symbol("time.Nanosecond"):             time.Nanosecond,
+		Symbol("time.NewTicker"):              gofunc(time.NewTicker),
+		Symbol("time.NewTimer"):               gofunc(time.NewTimer),
+		Symbol("time.November"):               time.November,
+		Symbol("time.Now"):                    gofunc(time.Now),
+		Symbol("time.October"):                time.October,
+		Symbol("time.Parse"):                  gofunc(time.Parse),
+		Symbol("time.ParseDuration"):          gofunc(time.ParseDuration),
+		Symbol("time.ParseInLocation"):        gofunc(time.ParseInLocation),
+		Symbol("time.RFC1123"):                time.RFC1123,
+		Symbol("time.RFC1123Z"):               time.RFC1123Z,
+		Symbol("time.RFC3339"):                time.RFC3339,
+		Symbol("time.RFC3339Nano"):            time.RFC3339Nano,
+		Symbol("time.RFC822"):                 time.RFC822,
+		Symbol("time.RFC822Z"):                time.RFC822Z,
+		Symbol("time.RFC850"):                 time.RFC850,
+		Symbol("time.RubyDate"):               time.RubyDate,
+		Symbol("time.Saturday"):               time.Saturday,
+		Symbol("time.Second"):                 time.Second,
+		Symbol("time.September"):              time.September,
+		Symbol("time.Since"):                  gofunc(time.Since),
+		Symbol("time.Sleep"):                  gofunc(time.Sleep),
+		Symbol("time.Stamp"):                  time.Stamp,
+		Symbol("time.StampMicro"):             time.StampMicro,
+		Symbol("time.StampMilli"):             time.StampMilli,
+		Symbol("time.StampNano"):              time.StampNano,
+		Symbol("time.Sunday"):                 time.Sunday,
+		Symbol("time.Thursday"):               time.Thursday,
+		Symbol("time.Tick"):                   gofunc(time.Tick),
+		Symbol("time.Tuesday"):                time.Tuesday,
+		Symbol("time.Unix"):                   gofunc(time.Unix),
+		Symbol("time.UnixDate"):               time.UnixDate,
+		Symbol("time.UnixMicro"):              gofunc(time.UnixMicro),
+		Symbol("time.UnixMilli"):              gofunc(time.UnixMilli),
+		Symbol("time.Until"):                  gofunc(time.Until),
+		Symbol("time.Wednesday"):              time.Wednesday,
+	}
+}
@@ -0,0 +1,605 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type opcode byte
+
+const (
+	// push constants[arg]
+	opConst opcode = iota
+	// push the local in slot arg
+	opLocal
+	// pop a value into the local in slot arg
+	opSetLocal
+	// push the captured variable arg
+	opUpval
+	// push the global named by constants[arg]
+	opGlobal
+	// pop a value into the global named by constants[arg] and push the name
+	opDefGlobal
+	// discard the top of the stack (no arg)
+	opPop
+	// jump forward by arg
+	opJump
+	// pop a value and jump forward by arg if it isn't truthy
+	opJumpIfFalse
+	// if the top of the stack is :else, replace it with false and
+	// remember the clause that follows in the local in slot arg
+	opElse
+	// jump to the clause remembered in the local in slot arg, or push nil
+	opJumpElse
+	// call the function below the top arg values on the stack
+	opCall
+	// call the function below the top arg values on the stack in place
+	// of the current frame
+	opTailCall
+	// return the top of the stack from the current frame (no arg)
+	opReturn
+	// push a closure over the prototype in constants[arg]
+	opClosure
+	// pop arg values into a vector
+	opVector
+	// pop arg key value pairs into a map
+	opMap
+	// pop arg values into a set
+	opSet
+	// pop the visible variables and run the special form in constants[arg]
+	opSpecial
+	// pop values into the slots of the recur target in constants[arg] and
+	// jump back to its start
+	opRecur
+	// push the folded constant in constants[arg] if the globals it was
+	// folded with haven't been redefined, or else skip over the jump that
+	// follows to run the form instead
+	opFolded
+)
+
+// the compiled body of a function
+type proto struct {
+	name  string
+	arity int
+	// the last param collects any remaining args
+	variadic   bool
+	code       []byte
+	consts     []any
+	upvals     []upvalDesc
+	localNames []Symbol
+	upvalNames []Symbol
+}
+
+// where a closure finds a captured variable when it is created
+type upvalDesc struct {
+	index int
+	// capture a local of the enclosing frame instead of one of its upvalues
+	isLocal bool
+}
+
+// a special form that the compiler doesn't know how to generate bytecode
+// for, analyzed into a closure that runs in a frame of the visible variables
+type specialCall struct {
+	vars []specialVar
+	size int
+	body compiled
+}
+
+// a variable visible to a special form, which is copied into its frame and
+// back out again in case the special form defined it
+type specialVar struct {
+	index int
+	// an upvalue of the closure instead of a local
+	isUpval bool
+}
+
+// a constant folded at compile time, and the globals it was folded with
+type foldedConst struct {
+	val  any
+	deps []foldDep
+}
+
+// where recur jumps back to, and the consecutive slots that it rebinds
+type recurTarget struct {
+	start int
+	first int
+	n     int
+}
+
+// the bytecode compiler for a single function body
+type bcCompiler struct {
+	proto     *proto
+	locals    []Symbol
+	enclosing *bcCompiler
+	globals   *Env
+	inFn      bool
+	// the innermost fn or loop, nil at the top level
+	recur *recurTarget
+	// inside of a loop that isn't in tail position, whose tail positions
+	// can't replace the frame
+	noTailCalls bool
+}
+
+// generate bytecode for the special forms that have a direct translation
+var bcForms map[uintptr]func(c *bcCompiler, args []any, tail bool) error
+
+func init() {
+	bcForms = map[uintptr]func(c *bcCompiler, args []any, tail bool) error{
+		reflect.ValueOf(specialform(quote)).Pointer():     (*bcCompiler).compileQuote,
+		reflect.ValueOf(specialform(do)).Pointer():        (*bcCompiler).compileBody,
+		reflect.ValueOf(specialform(def)).Pointer():       (*bcCompiler).compileDef,
+		reflect.ValueOf(specialform(fn)).Pointer():        (*bcCompiler).compileFnForm,
+		reflect.ValueOf(specialform(defn)).Pointer():      (*bcCompiler).compileDefn,
+		reflect.ValueOf(specialform(ifprim)).Pointer():    (*bcCompiler).compileIf,
+		reflect.ValueOf(specialform(cond)).Pointer():      (*bcCompiler).compileCond,
+		reflect.ValueOf(specialform(loopform)).Pointer():  (*bcCompiler).compileLoop,
+		reflect.ValueOf(specialform(recurform)).Pointer(): (*bcCompiler).compileRecur,
+	}
+}
+
+// Compile a top level form into a function that takes no arguments
+func compileTop(val any, env *Env) (*proto, error) {
+	c := &bcCompiler{proto: &proto{}, globals: env}
+	if err := c.compile(val, false); err != nil {
+		return nil, err
+	}
+	c.emit(opReturn)
+	c.proto.localNames = c.locals
+	return c.proto, nil
+}
+
+func (c *bcCompiler) compile(val any, tail bool) error {
+	switch t := val.(type) {
+	case Symbol:
+		return c.compileSymbol(t)
+	case []any:
+		for _, v := range t {
+			if err := c.compile(v, false); err != nil {
+				return err
+			}
+		}
+		return c.emitArg(opVector, len(t))
+	case map[any]any:
+		return c.compileMap(mapPairs(t))
+	case mapForm:
+		return c.compileMap(t)
+	case Set:
+		return c.compileSet(setItems(t))
+	case setForm:
+		return c.compileSet(t)
+	case List:
+		if len(t) == 0 {
+			return c.emitConst(t)
+		}
+
+		if expanded, isInterop := expandInterop(t); isInterop {
+			return c.compileApplication(expanded, tail)
+		}
+
+		if head, isSym := t[0].(Symbol); isSym {
+			spec, isSpec := c.resolveGlobal(head).(specialform)
+			if isSpec {
+				form, hasBytecode := bcForms[reflect.ValueOf(spec).Pointer()]
+				if hasBytecode {
+					return form(c, t[1:], tail)
+				}
+				return c.compileSpecial(spec, t[1:])
+			}
+		}
+
+		folded, deps, isConst := foldConstant(t, c)
+		if isConst {
+			return c.compileFolded(&foldedConst{folded, deps}, t, tail)
+		}
+
+		return c.compileApplication(t, tail)
+	default:
+		return c.emitConst(t)
+	}
+}
+
+func (c *bcCompiler) compileMap(pairs []any) error {
+	for _, item := range pairs {
+		if err := c.compile(item, false); err != nil {
+			return err
+		}
+	}
+	return c.emitArg(opMap, len(pairs)/2)
+}
+
+func (c *bcCompiler) compileSet(items []any) error {
+	for _, item := range items {
+		if err := c.compile(item, false); err != nil {
+			return err
+		}
+	}
+	return c.emitArg(opSet, len(items))
+}
+
+func (c *bcCompiler) compileSymbol(s Symbol) error {
+	if slot := c.resolveLocal(s); slot >= 0 {
+		return c.emitArg(opLocal, slot)
+	}
+	if idx := c.resolveUpval(s); idx >= 0 {
+		return c.emitArg(opUpval, idx)
+	}
+	return c.emitConstArg(opGlobal, s)
+}
+
+func (c *bcCompiler) compileApplication(val List, tail bool) error {
+	for _, v := range val {
+		if err := c.compile(v, false); err != nil {
+			return err
+		}
+	}
+	if tail && !c.noTailCalls {
+		return c.emitArg(opTailCall, len(val)-1)
+	}
+	return c.emitArg(opCall, len(val)-1)
+}
+
+// run a special form without a bytecode translation by analyzing it
+// with every visible variable bound in a frame below the globals
+func (c *bcCompiler) compileSpecial(spec specialform, args []any) error {
+	var names []Symbol
+	seen := make(map[Symbol]bool)
+	for fc := c; fc != nil; fc = fc.enclosing {
+		for i := len(fc.locals) - 1; i >= 0; i-- {
+			name := fc.locals[i]
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sc := childScope(newScope(c.globals), names)
+	body, err := spec(args, sc, false)
+	if err != nil {
+		return err
+	}
+
+	vars := make([]specialVar, len(names))
+	for i, name := range names {
+		if slot := c.resolveLocal(name); slot >= 0 {
+			vars[i] = specialVar{slot, false}
+		} else {
+			vars[i] = specialVar{c.resolveUpval(name), true}
+		}
+	}
+	// locals that the special form defined, like the analyzer does
+	for _, name := range sc.names[len(names):] {
+		if !c.inFn {
+			break
+		}
+		slot := c.resolveLocal(name)
+		if slot < 0 {
+			c.locals = append(c.locals, name)
+			slot = len(c.locals) - 1
+		}
+		vars = append(vars, specialVar{slot, false})
+	}
+	return c.emitConstArg(opSpecial, &specialCall{vars, len(sc.names), body})
+}
+
+// push a folded constant, with the form to run instead if its globals are
+// redefined
+func (c *bcCompiler) compileFolded(folded *foldedConst, form List, tail bool) error {
+	if err := c.emitConstArg(opFolded, folded); err != nil {
+		return err
+	}
+	end := c.emitJump(opJump)
+	if err := c.compileApplication(form, tail); err != nil {
+		return err
+	}
+	return c.patchJump(end)
+}
+
+func (c *bcCompiler) compileQuote(args []any, tail bool) error {
+	if err := checkQuote(args); err != nil {
+		return err
+	}
+	return c.emitConst(args[0])
+}
+
+func (c *bcCompiler) compileBody(body []any, tail bool) error {
+	if len(body) == 0 {
+		return c.emitConst(nil)
+	}
+	for i, form := range body {
+		last := i == len(body)-1
+		if err := c.compile(form, tail && last); err != nil {
+			return err
+		}
+		if !last {
+			c.emit(opPop)
+		}
+	}
+	return nil
+}
+
+func (c *bcCompiler) compileDef(args []any, tail bool) error {
+	sym, err := parseDef(args)
+	if err != nil {
+		return err
+	}
+	if err := c.compile(args[1], false); err != nil {
+		return err
+	}
+	return c.emitDefine(sym)
+}
+
+func (c *bcCompiler) compileDefn(args []any, tail bool) error {
+	sym, err := parseDefn(args)
+	if err != nil {
+		return err
+	}
+	if err := c.compileFn(string(sym), args[1:]); err != nil {
+		return err
+	}
+	return c.emitDefine(sym)
+}
+
+// pop a value into the variable defined by def, pushing the symbol
+func (c *bcCompiler) emitDefine(sym Symbol) error {
+	if !c.inFn {
+		return c.emitConstArg(opDefGlobal, sym)
+	}
+	// the slot was reserved by collectDefs when the function was compiled
+	if err := c.emitArg(opSetLocal, c.resolveLocal(sym)); err != nil {
+		return err
+	}
+	return c.emitConst(sym)
+}
+
+func (c *bcCompiler) compileFnForm(args []any, tail bool) error {
+	return c.compileFn("", args)
+}
+
+func (c *bcCompiler) compileFn(name string, args []any) error {
+	params, variadic, err := parseParams(args)
+	if err != nil {
+		return err
+	}
+
+	fc := &bcCompiler{
+		proto:     &proto{name: name, arity: len(params), variadic: variadic},
+		locals:    append([]Symbol{}, params...),
+		enclosing: c,
+		globals:   c.globals,
+		inFn:      true,
+		recur:     &recurTarget{0, 0, len(params)},
+	}
+	for _, sym := range collectDefs(args[1:], fc) {
+		if fc.resolveLocal(sym) < 0 {
+			fc.locals = append(fc.locals, sym)
+		}
+	}
+
+	if err := fc.compileBody(args[1:], true); err != nil {
+		return err
+	}
+	fc.emit(opReturn)
+	fc.proto.localNames = fc.locals
+
+	return c.emitConstArg(opClosure, fc.proto)
+}
+
+func (c *bcCompiler) compileIf(args []any, tail bool) error {
+	if err := checkArity("if", args, 2, 3); err != nil {
+		return err
+	}
+
+	if err := c.compile(args[0], false); err != nil {
+		return err
+	}
+	otherwise := c.emitJump(opJumpIfFalse)
+	if err := c.compile(args[1], tail); err != nil {
+		return err
+	}
+	end := c.emitJump(opJump)
+
+	if err := c.patchJump(otherwise); err != nil {
+		return err
+	}
+	if len(args) == 3 {
+		if err := c.compile(args[2], tail); err != nil {
+			return err
+		}
+	} else if err := c.emitConst(nil); err != nil {
+		return err
+	}
+	return c.patchJump(end)
+}
+
+// the bindings of a loop are consecutive locals of the current frame, so
+// that recur can set them and jump back to the start of the body
+func (c *bcCompiler) compileLoop(args []any, tail bool) error {
+	names, inits, err := parseLoop(args)
+	if err != nil {
+		return err
+	}
+
+	first := len(c.locals)
+	for range names {
+		c.addHidden()
+	}
+	for i, init := range inits {
+		if err := c.compile(init, false); err != nil {
+			return err
+		}
+		if err := c.emitArg(opSetLocal, first+i); err != nil {
+			return err
+		}
+		// each binding can refer to the ones before it
+		c.locals[first+i] = names[i]
+	}
+
+	recur, noTailCalls := c.recur, c.noTailCalls
+	c.recur = &recurTarget{len(c.proto.code), first, len(names)}
+	c.noTailCalls = noTailCalls || !tail
+	err = c.compileBody(args[1:], true)
+	c.recur, c.noTailCalls = recur, noTailCalls
+
+	// the bindings go out of scope, but keep their slots
+	for i := range names {
+		c.locals[first+i] = ""
+	}
+	return err
+}
+
+func (c *bcCompiler) compileRecur(args []any, tail bool) error {
+	nparams := 0
+	if c.recur != nil {
+		nparams = c.recur.n
+	}
+	if err := checkRecur(args, tail, c.recur != nil, nparams); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := c.compile(arg, false); err != nil {
+			return err
+		}
+	}
+	return c.emitConstArg(opRecur, c.recur)
+}
+
+// clauses with an :else test are only used when no other clause matches,
+// so each test can remember the clause to jump to at the end
+func (c *bcCompiler) compileCond(args []any, tail bool) error {
+	if err := checkCond(args); err != nil {
+		return err
+	}
+
+	elseSlot := c.addHidden()
+	if err := c.emitConst(nil); err != nil {
+		return err
+	}
+	if err := c.emitArg(opSetLocal, elseSlot); err != nil {
+		return err
+	}
+
+	var ends []int
+	for i := 0; i < len(args); i += 2 {
+		if err := c.compile(args[i], false); err != nil {
+			return err
+		}
+		if err := c.emitArg(opElse, elseSlot); err != nil {
+			return err
+		}
+		next := c.emitJump(opJumpIfFalse)
+		if err := c.compile(args[i+1], tail); err != nil {
+			return err
+		}
+		ends = append(ends, c.emitJump(opJump))
+		if err := c.patchJump(next); err != nil {
+			return err
+		}
+	}
+
+	if err := c.emitArg(opJumpElse, elseSlot); err != nil {
+		return err
+	}
+	for _, end := range ends {
+		if err := c.patchJump(end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Variable resolution
+
+func (c *bcCompiler) resolveLocal(s Symbol) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i] == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *bcCompiler) resolveUpval(s Symbol) int {
+	if c.enclosing == nil {
+		return -1
+	}
+	if slot := c.enclosing.resolveLocal(s); slot >= 0 {
+		return c.addUpval(s, slot, true)
+	}
+	if idx := c.enclosing.resolveUpval(s); idx >= 0 {
+		return c.addUpval(s, idx, false)
+	}
+	return -1
+}
+
+func (c *bcCompiler) addUpval(s Symbol, index int, isLocal bool) int {
+	for i, u := range c.proto.upvals {
+		if u.index == index && u.isLocal == isLocal {
+			return i
+		}
+	}
+	c.proto.upvals = append(c.proto.upvals, upvalDesc{index, isLocal})
+	c.proto.upvalNames = append(c.proto.upvalNames, s)
+	return len(c.proto.upvals) - 1
+}
+
+// reserve a local slot that can't be referred to by name
+func (c *bcCompiler) addHidden() int {
+	c.locals = append(c.locals, "")
+	return len(c.locals) - 1
+}
+
+func (c *bcCompiler) resolveGlobal(s Symbol) any {
+	for fc := c; fc != nil; fc = fc.enclosing {
+		if fc.resolveLocal(s) >= 0 {
+			return nil
+		}
+	}
+	val, err := c.globals.Find(s)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// Code generation
+
+const maxArg = 1<<16 - 1
+
+func (c *bcCompiler) emit(op opcode) {
+	c.proto.code = append(c.proto.code, byte(op))
+}
+
+func (c *bcCompiler) emitArg(op opcode, arg int) error {
+	if arg > maxArg {
+		return fmt.Errorf("too many values in function body: %d", arg)
+	}
+	c.proto.code = append(c.proto.code, byte(op), byte(arg>>8), byte(arg))
+	return nil
+}
+
+func (c *bcCompiler) emitConst(val any) error {
+	return c.emitConstArg(opConst, val)
+}
+
+func (c *bcCompiler) emitConstArg(op opcode, val any) error {
+	c.proto.consts = append(c.proto.consts, val)
+	return c.emitArg(op, len(c.proto.consts)-1)
+}
+
+// emit a jump and return the location of its offset for patchJump
+func (c *bcCompiler) emitJump(op opcode) int {
+	c.proto.code = append(c.proto.code, byte(op), 0xff, 0xff)
+	return len(c.proto.code) - 2
+}
+
+// point a jump at the next instruction to be emitted
+func (c *bcCompiler) patchJump(at int) error {
+	offset := len(c.proto.code) - at - 2
+	if offset > maxArg {
+		return fmt.Errorf("too much code to jump over: %d", offset)
+	}
+	c.proto.code[at] = byte(offset >> 8)
+	c.proto.code[at+1] = byte(offset)
+	return nil
+}
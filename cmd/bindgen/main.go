@@ -0,0 +1,279 @@
+// Command bindgen generates golisp bindings for the exported functions and
+// constants of go packages.
+//
+// Usage:
+//
+//	bindgen -o bindings_gen.go strings math path/filepath
+//
+// Each package is registered in the packages map under its import path, with
+// symbols qualified by the package name (strings.ToUpper, math.Pi, ...), so
+// that it can be imported into an environment.
+//
+// Functions and constants added to the standard library after the go version
+// of the module (the go directive of ./go.mod, or the -go flag) are skipped,
+// so that the bindings build with that version.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	out := flag.String("o", "bindings_gen.go", "the file to write")
+	pkgName := flag.String("pkg", "main", "the package of the generated file")
+	goVersion := flag.String("go", "", "the go version the bindings must build with (default from go.mod)")
+	flag.Parse()
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		log.Fatal("no packages to generate bindings for")
+	}
+	sort.Strings(paths)
+
+	if *goVersion == "" {
+		version, err := modGoVersion("go.mod")
+		if err != nil {
+			log.Fatal(err)
+		}
+		*goVersion = version
+	}
+	newer, err := newerSymbols(*goVersion)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(*pkgName, paths, newer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type binding struct {
+	symbol string
+	expr   string
+}
+
+// the go directive of a go.mod file, e.g. "1.18"
+func modGoVersion(path string) (string, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(src), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "go" {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("no go directive in %s", path)
+}
+
+// the functions and constants of the standard library that were added after
+// a go version, as "path.Name", from the api files of the go distribution
+func newerSymbols(version string) (map[string]bool, error) {
+	minor, err := goMinor(version)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(build.Default.GOROOT, "api", "go1.*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]bool)
+	for _, file := range files {
+		fileMinor, err := goMinor(strings.TrimSuffix(filepath.Base(file), ".txt"))
+		if err != nil || fileMinor <= minor {
+			continue
+		}
+		if err := readAPI(file, ret); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// the minor version of "1.18", "go1.18" or "1.18.2"
+func goMinor(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "go"), ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("invalid go version: %s", version)
+	}
+	return strconv.Atoi(parts[1])
+}
+
+// add the functions and constants of an api file, which has lines like
+// "pkg fmt, func Append([]uint8, ...interface{}) []uint8"
+func readAPI(file string, symbols map[string]bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "pkg ")
+		comma := strings.Index(line, ", ")
+		if comma < 0 {
+			continue
+		}
+		path, decl := line[:comma], line[comma+2:]
+		if strings.Contains(path, " (") {
+			// platform specific lines like "pkg math (linux-386), ..." are
+			// for new ports, which have the symbols of other platforms
+			continue
+		}
+
+		var name string
+		switch {
+		case strings.HasPrefix(decl, "func "):
+			name = strings.TrimPrefix(decl, "func ")
+			if paren := strings.IndexAny(name, "(["); paren >= 0 {
+				name = name[:paren]
+			}
+		case strings.HasPrefix(decl, "const "):
+			name = strings.Fields(strings.TrimPrefix(decl, "const "))[0]
+		default:
+			continue
+		}
+		symbols[path+"."+name] = true
+	}
+	return scanner.Err()
+}
+
+func generate(pkgName string, paths []string, newer map[string]bool) ([]byte, error) {
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by bindgen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+
+	pkgs := make([]*types.Package, len(paths))
+	aliases := make([]string, len(paths))
+	used := make(map[string]bool)
+	for i, path := range paths {
+		pkg, err := imp.Import(path)
+		if err != nil {
+			return nil, fmt.Errorf("importing %s: %v", path, err)
+		}
+		pkgs[i] = pkg
+
+		alias := pkg.Name()
+		for n := 2; used[alias]; n++ {
+			alias = fmt.Sprintf("%s%d", pkg.Name(), n)
+		}
+		used[alias] = true
+		aliases[i] = alias
+	}
+
+	fmt.Fprintf(&buf, "import (\n")
+	for i, path := range paths {
+		if aliases[i] == pkgs[i].Name() {
+			fmt.Fprintf(&buf, "\t%q\n", path)
+		} else {
+			fmt.Fprintf(&buf, "\t%s %q\n", aliases[i], path)
+		}
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+
+	fmt.Fprintf(&buf, "func init() {\n")
+	for i, pkg := range pkgs {
+		fmt.Fprintf(&buf, "\tpackages[%q] = map[Symbol]any{\n", paths[i])
+		for _, b := range bindings(pkg, aliases[i], newer) {
+			fmt.Fprintf(&buf, "\t\tSymbol(%q): %s,\n", b.symbol, b.expr)
+		}
+		fmt.Fprintf(&buf, "\t}\n")
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+// the exported functions and constants of a package, except newer ones
+func bindings(pkg *types.Package, alias string, newer map[string]bool) []binding {
+	var ret []binding
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() || newer[pkg.Path()+"."+name] {
+			continue
+		}
+
+		symbol := pkg.Name() + "." + name
+		ref := alias + "." + name
+
+		switch t := obj.(type) {
+		case *types.Func:
+			sig := t.Type().(*types.Signature)
+			if sig.TypeParams().Len() > 0 {
+				// generic functions have to be instantiated before they can be called
+				continue
+			}
+			ret = append(ret, binding{symbol, fmt.Sprintf("gofunc(%s)", ref)})
+		case *types.Const:
+			expr, ok := constExpr(t, ref)
+			if ok {
+				ret = append(ret, binding{symbol, expr})
+			}
+		}
+	}
+	return ret
+}
+
+// convert untyped constants into the type that the interpreter uses for them
+func constExpr(c *types.Const, ref string) (string, bool) {
+	basic, isBasic := c.Type().(*types.Basic)
+	if !isBasic || basic.Info()&types.IsUntyped == 0 {
+		return ref, true
+	}
+
+	val := c.Val()
+	switch val.Kind() {
+	case constant.Bool:
+		return ref, true
+	case constant.String:
+		return ref, true
+	case constant.Float:
+		return fmt.Sprintf("float64(%s)", ref), true
+	case constant.Int:
+		if basic.Kind() == types.UntypedRune {
+			return fmt.Sprintf("rune(%s)", ref), true
+		}
+		if i, exact := constant.Int64Val(val); exact {
+			// int is only 32 bits on some platforms
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return fmt.Sprintf("int(%s)", ref), true
+			}
+			return fmt.Sprintf("int64(%s)", ref), true
+		}
+		if _, exact := constant.Uint64Val(val); exact {
+			return fmt.Sprintf("uint64(%s)", ref), true
+		}
+		return "", false
+	case constant.Complex:
+		return fmt.Sprintf("complex128(%s)", ref), true
+	default:
+		return "", false
+	}
+}
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"math/big"
+	"reflect"
+	"time"
+)
+
 func Equals(v1, v2 any) bool {
 	list1, isList1 := v1.(List)
 	list2, isList2 := v2.(List)
@@ -19,6 +25,55 @@ func Equals(v1, v2 any) bool {
 		return mapEquals(map1, map2)
 	}
 
+	set1, isSet1 := v1.(Set)
+	set2, isSet2 := v2.(Set)
+	if isSet1 && isSet2 {
+		return setEquals(set1, set2)
+	}
+
+	form1, isForm1 := v1.(setForm)
+	form2, isForm2 := v2.(setForm)
+	if isForm1 && isForm2 {
+		return sliceEquals(form1, form2)
+	}
+
+	record1, isRecord1 := v1.(*record)
+	record2, isRecord2 := v2.(*record)
+	if isRecord1 && isRecord2 {
+		return recordEquals(record1, record2)
+	}
+
+	pairs1, isPairs1 := v1.(mapForm)
+	pairs2, isPairs2 := v2.(mapForm)
+	if isPairs1 && isPairs2 {
+		return sliceEquals(pairs1, pairs2)
+	}
+
+	switch n1 := v1.(type) {
+	case *big.Int:
+		n2, isBig := v2.(*big.Int)
+		return isBig && n1.Cmp(n2) == 0
+	case *big.Rat:
+		n2, isBig := v2.(*big.Rat)
+		return isBig && n1.Cmp(n2) == 0
+	case *big.Float:
+		n2, isBig := v2.(*big.Float)
+		return isBig && n1.Cmp(n2) == 0
+	}
+
+	time1, isTime1 := v1.(time.Time)
+	time2, isTime2 := v2.(time.Time)
+	if isTime1 && isTime2 {
+		return time1.Equal(time2)
+	}
+
+	if !isHashable(v1) || !isHashable(v2) {
+		// functions are only equal to themselves
+		if reflect.TypeOf(v1) == reflect.TypeOf(v2) && reflect.TypeOf(v1).Kind() == reflect.Func {
+			return sameFunc(v1, v2)
+		}
+		return false
+	}
 	return v1 == v2
 }
 
@@ -49,3 +104,15 @@ func mapEquals(map1, map2 map[any]any) bool {
 	}
 	return true
 }
+
+func setEquals(set1, set2 Set) bool {
+	if len(set1) != len(set2) {
+		return false
+	}
+	for k := range set1 {
+		if _, exists := set2[k]; !exists {
+			return false
+		}
+	}
+	return true
+}
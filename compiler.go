@@ -0,0 +1,512 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// a form that has been analyzed into a go closure which can be run
+// repeatedly without looking at the original form again
+type compiled func(env *Env) (any, error)
+
+// the lexical scope that a form is analyzed in, where each procedure
+// body gets a scope whose locals are stored in the slots of a frame
+type scope struct {
+	names   []Symbol
+	nparams int
+	// nil for the scope of the global environment
+	parent *scope
+	// the environment used to resolve globals while analyzing
+	env *Env
+	// the scope of a loop's bindings, rather than a procedure body
+	loop bool
+}
+
+func newScope(env *Env) *scope {
+	return &scope{env: env}
+}
+
+func childScope(parent *scope, params []Symbol) *scope {
+	names := append([]Symbol{}, params...)
+	return &scope{names, len(params), parent, parent.env, false}
+}
+
+// find the frame depth and slot of a local
+func (sc *scope) resolve(s Symbol) (depth, slot int, found bool) {
+	for ; sc.parent != nil; sc = sc.parent {
+		for i := len(sc.names) - 1; i >= 0; i-- {
+			if sc.names[i] == s {
+				return depth, i, true
+			}
+		}
+		depth++
+	}
+	return 0, 0, false
+}
+
+// the number of procedure frames between this scope and the globals
+func (sc *scope) depth() int {
+	depth := 0
+	for ; sc.parent != nil; sc = sc.parent {
+		depth++
+	}
+	return depth
+}
+
+// reserve a slot in the current frame for a local
+func (sc *scope) declare(s Symbol) int {
+	for i, name := range sc.names {
+		if name == s {
+			return i
+		}
+	}
+	sc.names = append(sc.names, s)
+	return len(sc.names) - 1
+}
+
+// look up the global value of a symbol at analysis time
+// (returns nil if the symbol is shadowed by a local)
+func (sc *scope) resolveGlobal(s Symbol) any {
+	if _, _, isLocal := sc.resolve(s); isLocal {
+		return nil
+	}
+	val, err := sc.env.Find(s)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// something that can look up the value of a global symbol at analysis time
+type resolver interface {
+	resolveGlobal(s Symbol) any
+}
+
+// Analyze a form into a compiled closure
+// (in tail position, procedure applications return a tailcall)
+func analyze(val any, sc *scope, tail bool) (compiled, error) {
+	switch t := val.(type) {
+	case Symbol:
+		return analyzeSymbol(t, sc), nil
+	case []any:
+		return analyzeVector(t, sc)
+	case map[any]any:
+		return analyzeMap(mapPairs(t), sc)
+	case mapForm:
+		return analyzeMap(t, sc)
+	case Set:
+		return analyzeSet(setItems(t), sc)
+	case setForm:
+		return analyzeSet(t, sc)
+	case List:
+		if len(t) == 0 {
+			return constant(t), nil
+		}
+
+		if expanded, isInterop := expandInterop(t); isInterop {
+			return analyzeApplication(expanded, sc, tail)
+		}
+
+		if head, isSym := t[0].(Symbol); isSym {
+			spec, isSpec := sc.resolveGlobal(head).(specialform)
+			if isSpec {
+				return spec(t[1:], sc, tail)
+			}
+		}
+
+		folded, deps, isConst := foldConstant(t, sc)
+		app, err := analyzeApplication(t, sc, tail)
+		if err != nil || !isConst {
+			return app, err
+		}
+		return foldedConstant(folded, deps, sc.depth(), app), nil
+	default:
+		return constant(t), nil
+	}
+}
+
+func analyzeSymbol(s Symbol, sc *scope) compiled {
+	depth, slot, isLocal := sc.resolve(s)
+	if !isLocal {
+		depth = sc.depth()
+		return func(env *Env) (any, error) {
+			for i := 0; i < depth; i++ {
+				env = env.parent
+			}
+			return env.Find(s)
+		}
+	}
+
+	frame := sc
+	for i := 0; i < depth; i++ {
+		frame = frame.parent
+	}
+	if slot < frame.nparams {
+		if depth == 0 {
+			return func(env *Env) (any, error) {
+				return env.slots[slot], nil
+			}
+		}
+		return func(env *Env) (any, error) {
+			for i := 0; i < depth; i++ {
+				env = env.parent
+			}
+			return env.slots[slot], nil
+		}
+	}
+
+	// locals created by def might not be defined yet
+	return func(env *Env) (any, error) {
+		for i := 0; i < depth; i++ {
+			env = env.parent
+		}
+		val := env.slots[slot]
+		if _, isUnbound := val.(unboundVar); isUnbound {
+			return nil, unresolved(s)
+		}
+		return val, nil
+	}
+}
+
+func constant(val any) compiled {
+	return func(env *Env) (any, error) {
+		return val, nil
+	}
+}
+
+// analyze all elements in a slice
+func analyzeSlice(val []any, sc *scope) ([]compiled, error) {
+	ret := make([]compiled, len(val))
+	for i, v := range val {
+		c, err := analyze(v, sc, false)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = c
+	}
+	return ret, nil
+}
+
+// run all compiled elements in a slice
+func runSlice(val []compiled, env *Env) ([]any, error) {
+	arr := make([]any, len(val))
+	for i, c := range val {
+		res, err := c(env)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = res
+	}
+	return arr, nil
+}
+
+func analyzeVector(val []any, sc *scope) (compiled, error) {
+	items, err := analyzeSlice(val, sc)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (any, error) {
+		if err := charge(env, 0, int64(len(items))*slotSize); err != nil {
+			return nil, err
+		}
+		return runSlice(items, env)
+	}, nil
+}
+
+func analyzeMap(pairs []any, sc *scope) (compiled, error) {
+	c, err := analyzeSlice(pairs, sc)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (any, error) {
+		if err := charge(env, 0, int64(len(c))*slotSize); err != nil {
+			return nil, err
+		}
+		vals, err := runSlice(c, env)
+		if err != nil {
+			return nil, err
+		}
+		return buildMap(vals)
+	}, nil
+}
+
+// the alternating keys and values of a map
+func mapPairs(val map[any]any) []any {
+	pairs := make([]any, 0, 2*len(val))
+	for k, v := range val {
+		pairs = append(pairs, k, v)
+	}
+	return pairs
+}
+
+// build a map from evaluated keys and values
+func buildMap(pairs []any) (map[any]any, error) {
+	ret := make(map[any]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		if !isHashable(pairs[i]) {
+			return nil, fmt.Errorf("map key must be a hashable value: %v", Print(pairs[i]))
+		}
+		ret[pairs[i]] = pairs[i+1]
+	}
+	return ret, nil
+}
+
+func analyzeSet(items []any, sc *scope) (compiled, error) {
+	c, err := analyzeSlice(items, sc)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (any, error) {
+		if err := charge(env, 0, int64(len(c))*slotSize); err != nil {
+			return nil, err
+		}
+		vals, err := runSlice(c, env)
+		if err != nil {
+			return nil, err
+		}
+		return buildSet(vals)
+	}, nil
+}
+
+func setItems(val Set) []any {
+	items := make([]any, 0, len(val))
+	for k := range val {
+		items = append(items, k)
+	}
+	return items
+}
+
+// build a set from evaluated items
+func buildSet(items []any) (Set, error) {
+	ret := make(Set, len(items))
+	for _, item := range items {
+		if !isHashable(item) {
+			return nil, fmt.Errorf("set item must be a hashable value: %v", Print(item))
+		}
+		ret[item] = struct{}{}
+	}
+	return ret, nil
+}
+
+func analyzeApplication(val List, sc *scope, tail bool) (compiled, error) {
+	front, err := analyze(val[0], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	args, err := analyzeSlice(val[1:], sc)
+	if err != nil {
+		return nil, err
+	}
+	interop := sc.env.root().interop
+
+	if tail {
+		return func(env *Env) (any, error) {
+			f, err := front(env)
+			if err != nil {
+				return nil, err
+			}
+			vals, err := runSlice(args, env)
+			if err != nil {
+				return nil, err
+			}
+			for {
+				tp, isTailPrim := f.(tailPrimitive)
+				if !isTailPrim {
+					break
+				}
+				if f, vals, err = tp(vals); err != nil {
+					return nil, err
+				}
+			}
+			proc, isProc := f.(*procedure)
+			if isProc {
+				return tailcall{proc, vals}, nil
+			}
+			return invokeIn(f, vals, interop)
+		}, nil
+	}
+
+	return func(env *Env) (any, error) {
+		f, err := front(env)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := runSlice(args, env)
+		if err != nil {
+			return nil, err
+		}
+		return invokeIn(f, vals, interop)
+	}, nil
+}
+
+// analyze the body of a do block or procedure
+func analyzeBody(body []any, sc *scope, tail bool) (compiled, error) {
+	if len(body) == 0 {
+		return constant(nil), nil
+	}
+
+	forms, err := analyzeSlice(body[:len(body)-1], sc)
+	if err != nil {
+		return nil, err
+	}
+	last, err := analyze(body[len(body)-1], sc, tail)
+	if err != nil {
+		return nil, err
+	}
+	if len(forms) == 0 {
+		return last, nil
+	}
+
+	return func(env *Env) (any, error) {
+		for _, f := range forms {
+			if _, err := f(env); err != nil {
+				return nil, err
+			}
+		}
+		return last(env)
+	}, nil
+}
+
+// primitives without side effects that can be run at analysis time
+var foldable map[uintptr]bool
+
+func init() {
+	foldable = make(map[uintptr]bool)
+	for _, prim := range []primitive{add, sub, mul, div, eq, lt, lte, gt, gte} {
+		foldable[reflect.ValueOf(prim).Pointer()] = true
+	}
+}
+
+// a global that a constant was folded with, which has to still be bound to
+// the same primitive for the constant to be used
+type foldDep struct {
+	sym  Symbol
+	prim uintptr
+}
+
+// Compute the value of a form at analysis time if it is made up of
+// literals and side effect free primitives, along with the globals that
+// it used
+func foldConstant(val any, r resolver) (any, []foldDep, bool) {
+	switch t := val.(type) {
+	case Symbol, []any, map[any]any, mapForm, Set, setForm:
+		return nil, nil, false
+	case List:
+		if len(t) == 0 {
+			return nil, nil, false
+		}
+		head, isSym := t[0].(Symbol)
+		if !isSym {
+			return nil, nil, false
+		}
+		prim, isPrim := r.resolveGlobal(head).(primitive)
+		if !isPrim || !foldable[reflect.ValueOf(prim).Pointer()] {
+			return nil, nil, false
+		}
+
+		deps := []foldDep{{head, reflect.ValueOf(prim).Pointer()}}
+		args := make([]any, len(t)-1)
+		for i, arg := range t[1:] {
+			folded, argDeps, isConst := foldConstant(arg, r)
+			if !isConst {
+				return nil, nil, false
+			}
+			args[i] = folded
+			deps = append(deps, argDeps...)
+		}
+
+		// leave errors to be reported when the form is run
+		ret, err := prim(args)
+		if err != nil {
+			return nil, nil, false
+		}
+		return ret, deps, true
+	default:
+		return t, nil, true
+	}
+}
+
+// whether the globals that a constant was folded with haven't been
+// redefined since
+func foldValid(globals *Env, deps []foldDep) bool {
+	for _, dep := range deps {
+		val, err := globals.Find(dep.sym)
+		if err != nil {
+			return false
+		}
+		prim, isPrim := val.(primitive)
+		if !isPrim || reflect.ValueOf(prim).Pointer() != dep.prim {
+			return false
+		}
+	}
+	return true
+}
+
+// a folded constant, which falls back to running the form if the globals
+// it was folded with were redefined
+func foldedConstant(val any, deps []foldDep, depth int, fallback compiled) compiled {
+	return func(env *Env) (any, error) {
+		globals := env
+		for i := 0; i < depth; i++ {
+			globals = globals.parent
+		}
+		if foldValid(globals, deps) {
+			return val, nil
+		}
+		return fallback(env)
+	}
+}
+
+// Find the symbols that def and defn forms in a procedure body will define,
+// without descending into nested procedures
+func collectDefs(body []any, r resolver) []Symbol {
+	var defs []Symbol
+	var walk func(val any)
+	walk = func(val any) {
+		switch t := val.(type) {
+		case []any:
+			for _, v := range t {
+				walk(v)
+			}
+		case List:
+			if len(t) == 0 {
+				return
+			}
+			if head, isSym := t[0].(Symbol); isSym {
+				spec, isSpec := r.resolveGlobal(head).(specialform)
+				if isSpec && (sameFunc(spec, def) || sameFunc(spec, defn)) && len(t) > 1 {
+					if sym, isSym := t[1].(Symbol); isSym {
+						defs = append(defs, sym)
+					}
+				}
+				// only look inside of special forms that run in the same scope
+				if isSpec && !sameFunc(spec, def) && !sameFunc(spec, do) &&
+					!sameFunc(spec, ifprim) && !sameFunc(spec, cond) && !sameFunc(spec, loopform) {
+					return
+				}
+			}
+			for _, v := range t {
+				walk(v)
+			}
+		}
+	}
+	for _, form := range body {
+		walk(form)
+	}
+	return defs
+}
+
+func sameFunc(f1, f2 any) bool {
+	return reflect.ValueOf(f1).Pointer() == reflect.ValueOf(f2).Pointer()
+}
+
+// check the number of arguments passed to a special form
+func checkArity(name string, args []any, min, max int) error {
+	if len(args) < min {
+		return fmt.Errorf("too few arguments to %s", name)
+	}
+	if max >= 0 && len(args) > max {
+		return fmt.Errorf("too many arguments to %s", name)
+	}
+	return nil
+}
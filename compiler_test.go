@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+const fibRecursive = `
+(defn fib [n]
+	(if (< n 2)
+		n
+		(+ (fib (- n 1))
+		   (fib (- n 2)))))`
+
+const fibIterative = `
+(defn fib [n]
+	(defn fib-iter [curr next n]
+		(if (= n 0)
+			curr
+			(fib-iter next
+				(+ curr next)
+				(- n 1))))
+	(fib-iter 0 1 n))`
+
+func TestAnalyzeErrorsAtDefinition(t *testing.T) {
+	testEvalError(t, "(defn f [x] (if x))")
+	testEvalError(t, "(fn [x] (def))")
+	testEvalError(t, "(fn [x] (cond x))")
+	testEvalError(t, "(defn \"f\" [x] x)")
+}
+
+func TestShadowSpecialForm(t *testing.T) {
+	testEval(t, "((fn [if] (if 1)) {1 3})", 3)
+	testEval(t, "((fn [quote] (quote 1)) +)", 1)
+}
+
+func TestAliasSpecialForm(t *testing.T) {
+	testEval(t, "(def when if) (when true 1 2)", 1)
+	testEval(t, "(do (def when if) (when true 1 2))", 1)
+	testEval(t, "(do (do (def when if)) (when false 1 2))", 2)
+}
+
+func TestConstantFolding(t *testing.T) {
+	testEval(t, "(+ 1 (* 2 3))", 7)
+	testEval(t, "(< 1 (+ 1 1))", true)
+	testEval(t, "((fn [x] (+ x (* 2 3))) 1)", 7)
+	testEval(t, "(if false (+ 1 \"a\") 1)", 1)
+	testEvalError(t, "(+ 1 \"a\")")
+	testEvalError(t, "(/ 1 0)")
+
+	// folded constants are recomputed once a global they used is redefined
+	testEval(t, `
+		(defn f [] (+ 1 (* 2 3)))
+		(defn g [x] (+ x 2))
+		(def + -)
+		[(f) (g 1)]`, []any{-5, -1})
+	testEval(t, "(do (def * +) (* 2 3))", 5)
+	testEval(t, "(defn f [] (if true (+ 1 2))) (def + -) (f)", -1)
+}
+
+func TestLexicalAddressing(t *testing.T) {
+	testEval(t, "((((fn [a b] (fn [c] (fn [d] [a b c d]))) 1 2) 3) 4)", []any{1, 2, 3, 4})
+	testEval(t, "((fn [x] ((fn [x] x) 2)) 1)", 2)
+	testEval(t, "((fn [x] (def x 5) x) 1)", 5)
+	testEval(t, "(def y 3) ((fn [x] ((fn [] (+ x y)))) 1)", 4)
+	testEval(t, `
+		(defn f []
+			(defn g [] (h))
+			(defn h [] 7)
+			(g))
+		(f)`, 7)
+	testEvalError(t, "((fn [] z (def z 1)))")
+}
+
+func TestTailCalls(t *testing.T) {
+	testEval(t, `
+		(defn count-down [n]
+			(cond (= n 0) :done
+				  :else (do 1 (count-down (- n 1)))))
+		(count-down 100000)`, Keyword("done"))
+	testEval(t, `
+		(defn even? [n] (if (= n 0) true (odd? (- n 1))))
+		(defn odd? [n] (if (= n 0) false (even? (- n 1))))
+		(even? 100001)`, false)
+}
+
+func BenchmarkFibRecursive(b *testing.B) {
+	benchEval(b, fibRecursive, "(fib 20)", 6765)
+}
+
+func BenchmarkFibIterative(b *testing.B) {
+	benchEval(b, fibIterative, "(fib 80)", 23416728348467685)
+}
+
+func benchEval(b *testing.B, setup, input string, expected any) {
+	env := NewEnv()
+	if _, err := readEval(setup, env); err != nil {
+		b.Fatal(err)
+	}
+	form, err := Read(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val, err := Eval(form, env)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !Equals(val, expected) {
+			b.Fatalf("expected %v, got %v", expected, val)
+		}
+	}
+}
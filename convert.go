@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// Convert a lisp value into a value of a go type, coercing numbers across
+// kinds, sequences into slices, and maps into go maps or structs
+func toGo(val any, t reflect.Type) (reflect.Value, error) {
+	return toGoFor(val, t, nil)
+}
+
+// convert a value passed to a go call, whose callbacks report their errors
+// to the call, or with a nil call to stderr
+func toGoFor(val any, t reflect.Type, gc *goCall) (reflect.Value, error) {
+	if val == nil {
+		return reflect.Zero(t), nil
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return toGoNumber(v, t)
+	case reflect.Slice:
+		return toGoSlice(v, t, gc)
+	case reflect.Array:
+		return toGoArray(v, t, gc)
+	case reflect.Map:
+		return toGoMap(v, t, gc)
+	case reflect.Struct:
+		return toGoStruct(v, t, gc)
+	case reflect.Func:
+		return toGoFunc(val, t, gc)
+	case reflect.Pointer:
+		// pass a pointer to a converted value
+		elem, err := toGoFor(val, t.Elem(), gc)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+
+	if v.Type().ConvertibleTo(t) && v.Kind() == t.Kind() {
+		// named types with the same underlying kind, e.g. time.Duration
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, cantConvert(val, t)
+}
+
+func cantConvert(val any, t reflect.Type) error {
+	return fmt.Errorf("can't convert %s (%T) to %v", Print(val), val, t)
+}
+
+func toGoNumber(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	ret := reflect.New(t).Elem()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		switch {
+		case isIntKind(t.Kind()):
+			if ret.OverflowInt(i) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %v", i, t)
+			}
+			ret.SetInt(i)
+		case isUintKind(t.Kind()):
+			if i < 0 || ret.OverflowUint(uint64(i)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %v", i, t)
+			}
+			ret.SetUint(uint64(i))
+		default:
+			ret.SetFloat(float64(i))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		switch {
+		case isIntKind(t.Kind()):
+			if u > math.MaxInt64 || ret.OverflowInt(int64(u)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %v", u, t)
+			}
+			ret.SetInt(int64(u))
+		case isUintKind(t.Kind()):
+			if ret.OverflowUint(u) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %v", u, t)
+			}
+			ret.SetUint(u)
+		default:
+			ret.SetFloat(float64(u))
+		}
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+			ret.SetFloat(f)
+			return ret, nil
+		}
+		// only whole numbers can become integers
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return reflect.Value{}, fmt.Errorf("can't convert %v to %v without losing precision", f, t)
+		}
+		// converting a float outside the range of int64 or uint64 is
+		// implementation defined, so check the range first
+		switch {
+		case f >= math.MinInt64 && f < math.MaxInt64:
+			return toGoNumber(reflect.ValueOf(int64(f)), t)
+		case f >= 0 && f < math.MaxUint64:
+			return toGoNumber(reflect.ValueOf(uint64(f)), t)
+		default:
+			return reflect.Value{}, fmt.Errorf("%v overflows %v", f, t)
+		}
+	default:
+		return reflect.Value{}, cantConvert(v.Interface(), t)
+	}
+	return ret, nil
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+func toGoSlice(v reflect.Value, t reflect.Type, gc *goCall) (reflect.Value, error) {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return reflect.Value{}, cantConvert(v.Interface(), t)
+	}
+	ret := reflect.MakeSlice(t, v.Len(), v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem, err := toGoFor(v.Index(i).Interface(), t.Elem(), gc)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+		}
+		ret.Index(i).Set(elem)
+	}
+	return ret, nil
+}
+
+func toGoArray(v reflect.Value, t reflect.Type, gc *goCall) (reflect.Value, error) {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return reflect.Value{}, cantConvert(v.Interface(), t)
+	}
+	if v.Len() != t.Len() {
+		return reflect.Value{}, fmt.Errorf("wrong number of elements (%d) for %v", v.Len(), t)
+	}
+	ret := reflect.New(t).Elem()
+	for i := 0; i < v.Len(); i++ {
+		elem, err := toGoFor(v.Index(i).Interface(), t.Elem(), gc)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+		}
+		ret.Index(i).Set(elem)
+	}
+	return ret, nil
+}
+
+func toGoMap(v reflect.Value, t reflect.Type, gc *goCall) (reflect.Value, error) {
+	if v.Kind() != reflect.Map {
+		return reflect.Value{}, cantConvert(v.Interface(), t)
+	}
+	ret := reflect.MakeMapWithSize(t, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := toGoFor(iter.Key().Interface(), t.Key(), gc)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %v", Print(iter.Key().Interface()), err)
+		}
+		val, err := toGoFor(iter.Value().Interface(), t.Elem(), gc)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("value of %s: %v", Print(iter.Key().Interface()), err)
+		}
+		ret.SetMapIndex(key, val)
+	}
+	return ret, nil
+}
+
+// fill in the fields of a struct from a map whose keys are field names
+func toGoStruct(v reflect.Value, t reflect.Type, gc *goCall) (reflect.Value, error) {
+	if v.Kind() != reflect.Map {
+		return reflect.Value{}, cantConvert(v.Interface(), t)
+	}
+	ret := reflect.New(t).Elem()
+	iter := v.MapRange()
+	for iter.Next() {
+		name, isName := fieldName(iter.Key().Interface())
+		if !isName {
+			return reflect.Value{}, fmt.Errorf("field name must be a keyword, symbol or string: %s", Print(iter.Key().Interface()))
+		}
+		field, exists := findField(t, name)
+		if !exists {
+			return reflect.Value{}, fmt.Errorf("no field %s on %v", name, t)
+		}
+		val, err := toGoFor(iter.Value().Interface(), field.typ, gc)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %v", field.name, err)
+		}
+		dest, isSettable := settableField(ret, field.index)
+		if !isSettable {
+			return reflect.Value{}, fmt.Errorf("field %s on %v can't be set", field.name, t)
+		}
+		dest.Set(val)
+	}
+	return ret, nil
+}
+
+// find a nested field, allocating any embedded pointers on the way
+func settableField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					// an unexported embedded pointer
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, v.CanSet()
+}
+
+// a call from lisp into a go function, which keeps the first error from
+// the callbacks passed to it that have no error result, since go code has
+// no other way to stop on them. The callbacks can run on other goroutines.
+type goCall struct {
+	mu      sync.Mutex
+	running bool
+	err     error
+}
+
+// keep the error from a callback for the call, returning false if the call
+// has already returned
+func (gc *goCall) fail(err error) bool {
+	if gc == nil {
+		return false
+	}
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if !gc.running {
+		return false
+	}
+	if gc.err == nil {
+		gc.err = err
+	}
+	return true
+}
+
+// the error of a callback that failed while the call was running
+func (gc *goCall) failure() error {
+	if gc == nil {
+		return nil
+	}
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.err
+}
+
+// mark the call as returned, along with the error that failed it
+func (gc *goCall) finish() error {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.running = false
+	return gc.err
+}
+
+// where errors are reported from callbacks that have no error result and
+// ran after the call that they were passed to returned, e.g. by
+// time.AfterFunc, or that were never passed to a call, e.g. by set-field!
+var callbackErrors io.Writer = os.Stderr
+
+// wrap a lisp function so that go code can call it as a func of type t.
+// Environments aren't safe for concurrent use, so go code should only run
+// the func on another goroutine while the interpreter waits for it, e.g.
+// during the call that it was passed to, or while nothing else is evaluated.
+func toGoFunc(val any, t reflect.Type, gc *goCall) (reflect.Value, error) {
+	switch val.(type) {
+	case *procedure, *closure, primitive, *multiFn, *protocolFn:
+	default:
+		if reflect.ValueOf(val).Kind() != reflect.Func {
+			return reflect.Value{}, cantConvert(val, t)
+		}
+	}
+
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		if err := gc.failure(); err != nil {
+			// once a callback has failed the call, the rest fail without
+			// running
+			return callbackResults(t, nil, err, gc)
+		}
+		args := make([]any, len(in))
+		for i, arg := range in {
+			args[i] = fromGo(arg)
+		}
+		res, err := invoke(val, args)
+		return callbackResults(t, res, err, gc)
+	}), nil
+}
+
+// convert the result of a lisp function into the results of a go func,
+// returning errors as the error result if there is one
+func callbackResults(t reflect.Type, res any, err error, gc *goCall) []reflect.Value {
+	out := make([]reflect.Value, t.NumOut())
+	nvals := t.NumOut()
+	hasErr := nvals > 0 && t.Out(nvals-1) == errorType
+	if hasErr {
+		nvals--
+	}
+
+	if err == nil {
+		err = setResults(out[:nvals], t, res, gc)
+	}
+
+	if err != nil {
+		if !hasErr && !gc.fail(err) {
+			fmt.Fprintf(callbackErrors, "error in callback: %v\n", err)
+		}
+		for i := 0; i < nvals; i++ {
+			out[i] = reflect.Zero(t.Out(i))
+		}
+	}
+	if hasErr {
+		errVal := reflect.New(errorType).Elem()
+		if err != nil {
+			errVal.Set(reflect.ValueOf(err))
+		}
+		out[nvals] = errVal
+	}
+	return out
+}
+
+// convert a lisp value into the non-error results of a go func, where
+// multiple results are returned from lisp as a list
+func setResults(out []reflect.Value, t reflect.Type, res any, gc *goCall) error {
+	vals := []any{res}
+	if len(out) == 0 {
+		return nil
+	}
+	if len(out) > 1 {
+		seq := reflect.ValueOf(res)
+		if seq.Kind() != reflect.Slice || seq.Len() != len(out) {
+			return fmt.Errorf("callback must return %d results: %s", len(out), Print(res))
+		}
+		vals = make([]any, seq.Len())
+		for i := range vals {
+			vals[i] = seq.Index(i).Interface()
+		}
+	}
+
+	for i := range out {
+		val, err := toGoFor(vals[i], t.Out(i), gc)
+		if err != nil {
+			return fmt.Errorf("wrong type for result %d of callback: %v", i+1, err)
+		}
+		out[i] = val
+	}
+	return nil
+}
+
+// the name of a field given as a keyword, symbol or string
+func fieldName(key any) (string, bool) {
+	switch t := key.(type) {
+	case Keyword:
+		return string(t), true
+	case Symbol:
+		return string(t), true
+	case string:
+		return t, true
+	}
+	return "", false
+}
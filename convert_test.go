@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestConvertNumbers(t *testing.T) {
+	testEval(t, "(testtype 1)", "int64 1")
+	testEval(t, "(testtype 2.0)", "int64 2")
+	testEval(t, "(testfloat 3)", 3.0)
+	testEval(t, "(testbyte 255)", "255")
+	testEval(t, "(testtype nil)", "int64 0")
+	testEvalError(t, "(testtype 1.5)")
+	testEvalError(t, "(testbyte 256)")
+	testEvalError(t, "(testbyte -1)")
+	testEvalError(t, "(testtype 1e30)")
+	testEvalError(t, "(testtype -1e30)")
+	testEvalError(t, "(testtype 9223372036854775808.0)")
+	testEvalError(t, "(testbyte 1e30)")
+	testEvalError(t, "(testtype ##Inf)")
+	testEvalError(t, "(testtype ##NaN)")
+	testEvalError(t, "(testtype \"1\")")
+}
+
+func TestConvertSequences(t *testing.T) {
+	testEval(t, `(testjoin ["a" "b" "c"])`, "a,b,c")
+	testEval(t, `(testjoin (quote ("a" "b")))`, "a,b")
+	testEval(t, "(testjoin [])", "")
+	testEval(t, "(testjoin nil)", "")
+	testEval(t, "(testsum [1 2 3.0])", 6.0)
+	testEvalError(t, `(testjoin ["a" 1])`)
+	testEvalError(t, `(testjoin "a")`)
+}
+
+func TestConvertMaps(t *testing.T) {
+	testEval(t, `(testkeys {"b" 2 "a" 1})`, "a=1,b=2")
+	testEval(t, `(testkeys {:a 1})`, "a=1")
+	testEvalError(t, `(testkeys {"a" "b"})`)
+	testEvalError(t, `(testkeys {1 1})`)
+}
+
+func TestConvertStructs(t *testing.T) {
+	testEval(t, "(testpointsum {:X 1 :Y 2})", 3)
+	testEval(t, "(testpointsum {:x 1 :tags [\"a\"]})", 1)
+	testEval(t, "(testpointnil nil)", true)
+	testEval(t, "(testpointnil {})", false)
+	testEvalError(t, "(testpointsum {:Z 1})")
+	testEvalError(t, "(testpointsum {:name \"a\"})")
+	testEvalError(t, "(testpointsum {:X \"a\"})")
+	testEvalError(t, "(testpointsum [1 2])")
+}
+
+func TestConvertSetField(t *testing.T) {
+	testEval(t, `
+		(def p (testpoint 1 2))
+		(set-field! p :Tags ["c" "d"])
+		(.-Tags p)`, List{"c", "d"})
+	testEval(t, `
+		(def p (testpoint 1 2))
+		(set-field! p :X 5.0)
+		(.-X p)`, 5)
+}
+
+func TestCallbacks(t *testing.T) {
+	testEval(t, `(testmap (fn [r] \x) "abc")`, "xxx")
+	testEval(t, `(testsort [3 1 2] (fn [a b] (< a b)))`, List{1, 2, 3})
+	testEval(t, `(testsort [3 1 2] >)`, List{3, 2, 1})
+	testEval(t, `(testsort [3 1 2] (fn [a b] (testless a b)))`, List{1, 2, 3})
+	testEval(t, `(testsort [1 2] testless)`, List{1, 2})
+	testEval(t, `(testeach [1 2 3] (fn [x] x))`, nil)
+	testEval(t, `(testpair (fn [x] (quote (1 "a"))))`, "1 a")
+}
+
+func TestCallbackErrors(t *testing.T) {
+	// errors are returned through an error result
+	testEvalError(t, `(testeach [1 2] (fn [x] (undefined x)))`)
+	// or recovered if the callback has no error result
+	testEvalError(t, `(testmap (fn [r] (undefined r)) "abc")`)
+	testEvalError(t, `(testmap (fn [r] "a") "abc")`)
+	testEvalError(t, `(testmap (fn [a b] a) "abc")`)
+	testEvalError(t, `(testpair (fn [x] 1))`)
+	testEvalError(t, `(testmap 1 "abc")`)
+}
+
+func TestCallbackOtherGoroutine(t *testing.T) {
+	// a callback on another goroutine fails the call that is waiting for it
+	testEvalError(t, `(testasync (fn [] (undefined)))`)
+	testEval(t, `(testasync (fn [] 5))`, 5)
+}
+
+func TestCallbackAfterReturn(t *testing.T) {
+	var reported strings.Builder
+	callbackErrors = &reported
+	defer func() { callbackErrors = os.Stderr }()
+
+	// once the call has returned there is nothing left to fail, so the error
+	// is reported and the callback returns zero values
+	testEval(t, `(testlater (fn [] (undefined)))`, nil)
+	if ret := later(); ret != 0 {
+		t.Errorf("expected the callback to return zero, got: %d", ret)
+	}
+	if !strings.Contains(reported.String(), "error in callback") {
+		t.Errorf("expected the callback error to be reported, got: %q", reported.String())
+	}
+}
+
+// a callback kept by testlater, to run after the call has returned
+var later func() int
+
+func init() {
+	defaultEnv[Symbol("testmap")] = gofunc(strings.Map)
+	defaultEnv[Symbol("testless")] = gofunc(func(a, b int) bool {
+		return a < b
+	})
+	defaultEnv[Symbol("testsort")] = gofunc(func(s []int, less func(a, b int) bool) []int {
+		sort.Slice(s, func(i, j int) bool {
+			return less(s[i], s[j])
+		})
+		return s
+	})
+	defaultEnv[Symbol("testeach")] = gofunc(func(s []int, f func(int) error) error {
+		for _, x := range s {
+			if err := f(x); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	defaultEnv[Symbol("testasync")] = gofunc(func(f func() int) int {
+		ret := make(chan int)
+		go func() { ret <- f() }()
+		return <-ret
+	})
+	defaultEnv[Symbol("testlater")] = gofunc(func(f func() int) {
+		later = f
+	})
+	defaultEnv[Symbol("testpair")] = gofunc(func(f func(int) (int, string)) string {
+		n, s := f(0)
+		return fmt.Sprint(n, " ", s)
+	})
+
+	defaultEnv[Symbol("testtype")] = gofunc(func(x int64) string {
+		return fmt.Sprintf("%T %d", x, x)
+	})
+	defaultEnv[Symbol("testfloat")] = gofunc(func(x float64) float64 {
+		return x
+	})
+	defaultEnv[Symbol("testbyte")] = gofunc(func(x uint8) string {
+		return fmt.Sprint(x)
+	})
+	defaultEnv[Symbol("testjoin")] = gofunc(func(s []string) string {
+		return strings.Join(s, ",")
+	})
+	defaultEnv[Symbol("testsum")] = gofunc(func(s []float64) float64 {
+		sum := 0.0
+		for _, x := range s {
+			sum += x
+		}
+		return sum
+	})
+	defaultEnv[Symbol("testkeys")] = gofunc(func(m map[string]int) string {
+		pairs := make([]string, 0, len(m))
+		for k, v := range m {
+			pairs = append(pairs, fmt.Sprintf("%s=%d", k, v))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	})
+	defaultEnv[Symbol("testpointsum")] = gofunc(func(p testPoint) int {
+		return p.Sum()
+	})
+	defaultEnv[Symbol("testpointnil")] = gofunc(func(p *testPoint) bool {
+		return p == nil
+	})
+}
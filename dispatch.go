@@ -0,0 +1,589 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// Multimethods
+
+// A multimethod calls the method for the value that its dispatch function
+// returns for the args, or for a value that it isa?
+type multiFn struct {
+	name     string
+	dispatch any
+	methods  []multiMethod
+	// the global environment whose hierarchy is used to match methods
+	globals *Env
+}
+
+type multiMethod struct {
+	value any
+	fn    any
+}
+
+// the dispatch value of the method that is used when no other matches
+var defaultKeyword = Keyword("default")
+
+// (defmulti name dispatch-fn) defines a multimethod, which is always global
+func defmulti(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkArity("defmulti", args, 2, 3); err != nil {
+		return nil, err
+	}
+	sym, isSym := args[0].(Symbol)
+	if !isSym {
+		return nil, fmt.Errorf("first argument to defmulti must be a Symbol")
+	}
+	if _, isDoc := args[1].(string); len(args) == 3 && !isDoc {
+		return nil, fmt.Errorf("too many arguments to defmulti")
+	}
+
+	set := globalDefiner(sym, sc)
+	dispatch, err := analyze(args[len(args)-1], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (any, error) {
+		d, err := dispatch(env)
+		if err != nil {
+			return nil, err
+		}
+		set(env, &multiFn{name: string(sym), dispatch: d, globals: env.root()})
+		return sym, nil
+	}, nil
+}
+
+// (defmethod name dispatch-value [params] body...) adds a method to a
+// multimethod, replacing any method for the same dispatch value
+func defmethod(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkArity("defmethod", args, 3, -1); err != nil {
+		return nil, err
+	}
+	sym, isSym := args[0].(Symbol)
+	if !isSym {
+		return nil, fmt.Errorf("first argument to defmethod must be a Symbol")
+	}
+
+	multi := analyzeSymbol(sym, sc)
+	value, err := analyze(args[1], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	method, err := analyzeFn(string(sym), args[2:], sc)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (any, error) {
+		m, err := multi(env)
+		if err != nil {
+			return nil, err
+		}
+		mf, isMulti := m.(*multiFn)
+		if !isMulti {
+			return nil, fmt.Errorf("%s is not a multimethod", sym)
+		}
+		v, err := value(env)
+		if err != nil {
+			return nil, err
+		}
+		f, err := method(env)
+		if err != nil {
+			return nil, err
+		}
+		mf.addMethod(v, f)
+		return mf, nil
+	}, nil
+}
+
+// inside of a procedure body def creates a local, but multimethods and
+// protocols are always defined as globals
+func globalDefiner(sym Symbol, sc *scope) func(env *Env, val any) {
+	depth := sc.depth()
+	return func(env *Env, val any) {
+		for i := 0; i < depth; i++ {
+			env = env.parent
+		}
+		env.Define(sym, val)
+	}
+}
+
+func (m *multiFn) addMethod(value, fn any) {
+	for i, method := range m.methods {
+		if Equals(method.value, value) {
+			m.methods[i].fn = fn
+			return
+		}
+	}
+	m.methods = append(m.methods, multiMethod{value, fn})
+}
+
+func (m *multiFn) call(args []any) (any, error) {
+	value, err := invoke(m.dispatch, args)
+	if err != nil {
+		return nil, err
+	}
+	method, err := m.find(value)
+	if err != nil {
+		return nil, err
+	}
+	return invoke(method, args)
+}
+
+// the method for a dispatch value: one for an equal value, else the most
+// specific one for a value it isa?, else the default
+func (m *multiFn) find(value any) (any, error) {
+	var matches []multiMethod
+	var fallback any
+	for _, method := range m.methods {
+		if Equals(method.value, value) {
+			return method.fn, nil
+		}
+		if Equals(method.value, defaultKeyword) {
+			fallback = method.fn
+		} else if m.globals.isa(value, method.value) {
+			matches = append(matches, method)
+		}
+	}
+
+	if len(matches) > 0 {
+		for _, match := range matches {
+			if m.dominates(match, matches) {
+				return match.fn, nil
+			}
+		}
+		return nil, fmt.Errorf("multiple methods in multimethod '%s' match dispatch value: %s -> %s and %s, and neither is preferred",
+			m.name, Print(value), Print(matches[0].value), Print(matches[1].value))
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("no method in multimethod '%s' for dispatch value: %s", m.name, Print(value))
+}
+
+// whether a method's dispatch value isa? the values of all the others
+func (m *multiFn) dominates(method multiMethod, others []multiMethod) bool {
+	for _, other := range others {
+		if !m.globals.isa(method.value, other.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hierarchies
+
+// (derive child parent) makes child isa? parent, in the hierarchy of the
+// global environment
+func derive(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to derive", len(args))
+		}
+		return nil, env.derive(args[0], args[1])
+	})
+}
+
+// (isa? child parent) is true for equal values, for values related by
+// derive, for go types that implement an interface type, and for vectors
+// whose items are each isa? the other's
+func isa(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to isa?", len(args))
+		}
+		return env.isa(args[0], args[1]), nil
+	})
+}
+
+func (e *Env) derive(child, parent any) error {
+	if !isHashable(child) || !isHashable(parent) {
+		return fmt.Errorf("can't derive %s from %s", Print(child), Print(parent))
+	}
+	if Equals(child, parent) {
+		return fmt.Errorf("can't derive %s from itself", Print(child))
+	}
+	if e.isa(parent, child) {
+		return fmt.Errorf("cyclic derivation: %s already isa %s", Print(parent), Print(child))
+	}
+
+	if e.parents == nil {
+		e.parents = make(map[any][]any)
+	}
+	for _, p := range e.parents[child] {
+		if Equals(p, parent) {
+			return nil
+		}
+	}
+	e.parents[child] = append(e.parents[child], parent)
+	return nil
+}
+
+func (e *Env) isa(child, parent any) bool {
+	if Equals(child, parent) {
+		return true
+	}
+	if ct, isType := child.(reflect.Type); isType {
+		pt, isParentType := parent.(reflect.Type)
+		if isParentType && pt.Kind() == reflect.Interface && ct.Implements(pt) {
+			return true
+		}
+	}
+	if cv, isVect := child.([]any); isVect {
+		pv, isParentVect := parent.([]any)
+		if !isParentVect || len(cv) != len(pv) {
+			return false
+		}
+		for i := range cv {
+			if !e.isa(cv[i], pv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !isHashable(child) {
+		return false
+	}
+	for _, p := range e.parents[child] {
+		if e.isa(p, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Protocols
+
+// A protocol is a named set of methods, which are implemented for types
+// with extend-type and dispatch on the type of their first arg
+type protocol struct {
+	name    string
+	methods []Symbol
+	// the implementations of each method, by type
+	impls map[any]map[Symbol]any
+	// the interface types that have been extended, in order
+	ifaces []reflect.Type
+}
+
+// a method of a protocol
+type protocolFn struct {
+	proto *protocol
+	name  Symbol
+}
+
+// (defprotocol Name (method [this args...]) ...) defines a protocol and
+// its methods as globals
+func defprotocol(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkArity("defprotocol", args, 1, -1); err != nil {
+		return nil, err
+	}
+	sym, isSym := args[0].(Symbol)
+	if !isSym {
+		return nil, fmt.Errorf("first argument to defprotocol must be a Symbol")
+	}
+
+	var methods []Symbol
+	for _, arg := range args[1:] {
+		switch t := arg.(type) {
+		case string:
+			// a docstring
+		case List:
+			name, err := parseSignature(t)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, name)
+		default:
+			return nil, fmt.Errorf("invalid protocol method: %s", Print(arg))
+		}
+	}
+
+	set := globalDefiner(sym, sc)
+	setMethods := make([]func(env *Env, val any), len(methods))
+	for i, name := range methods {
+		setMethods[i] = globalDefiner(name, sc)
+	}
+	return func(env *Env) (any, error) {
+		p := &protocol{name: string(sym), methods: methods, impls: make(map[any]map[Symbol]any)}
+		set(env, p)
+		for i, name := range methods {
+			setMethods[i](env, &protocolFn{p, name})
+		}
+		return sym, nil
+	}, nil
+}
+
+// the name of a protocol method from its signature: (name [this] "doc"),
+// where there can be a params vector for each arity
+func parseSignature(sig List) (Symbol, error) {
+	if len(sig) < 2 {
+		return "", fmt.Errorf("protocol method must have a name and params: %s", Print(sig))
+	}
+	name, isSym := sig[0].(Symbol)
+	if !isSym {
+		return "", fmt.Errorf("protocol method name must be a Symbol: %s", Print(sig))
+	}
+	for i, arg := range sig[1:] {
+		if _, isDoc := arg.(string); isDoc && i == len(sig)-2 {
+			continue
+		}
+		params, isVect := arg.([]any)
+		if !isVect {
+			return "", fmt.Errorf("invalid params for protocol method %s: %s", name, Print(arg))
+		}
+		if len(params) == 0 {
+			return "", fmt.Errorf("protocol method %s must take at least one arg", name)
+		}
+	}
+	return name, nil
+}
+
+// (extend-type type Protocol (method [this args...] body...) ...) implements
+// the methods of protocols for a type, which can be a record type or a go
+// reflect.Type
+func extendType(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkArity("extend-type", args, 2, -1); err != nil {
+		return nil, err
+	}
+	typ, err := analyze(args[0], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	impls, err := analyzeImpls("extend-type", args[1:], sc, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *Env) (any, error) {
+		t, err := typ(env)
+		if err != nil {
+			return nil, err
+		}
+		switch t.(type) {
+		case nil, reflect.Type, *recordType:
+		default:
+			return nil, fmt.Errorf("extend-type expects a type: %s", Print(t))
+		}
+		return nil, extendImpls(env, t, impls)
+	}, nil
+}
+
+// the implementation of a protocol method, in extend-type or defrecord
+type protocolImpl struct {
+	proto compiled
+	name  Symbol
+	fn    compiled
+	// whether the fields of a record are passed before the args
+	fields bool
+}
+
+// analyze the protocols and method implementations that follow them, where
+// fields are the params of a record that are in scope in each method
+func analyzeImpls(form string, args []any, sc *scope, fields []Symbol) ([]protocolImpl, error) {
+	var impls []protocolImpl
+	var proto compiled
+	for _, arg := range args {
+		switch t := arg.(type) {
+		case Symbol:
+			proto = analyzeSymbol(t, sc)
+		case List:
+			if proto == nil {
+				return nil, fmt.Errorf("%s methods must follow a protocol: %s", form, Print(t))
+			}
+			name, isSym := t[0].(Symbol)
+			if len(t) < 2 || !isSym {
+				return nil, fmt.Errorf("invalid method implementation: %s", Print(t))
+			}
+			fnArgs := t[1:]
+			if params, isVect := fnArgs[0].([]any); isVect && fields != nil {
+				withFields := make([]any, 0, len(fields)+len(params))
+				for _, field := range fields {
+					withFields = append(withFields, field)
+				}
+				fnArgs = append(List{append(withFields, params...)}, fnArgs[1:]...)
+			}
+			fn, err := analyzeFn(string(name), fnArgs, sc)
+			if err != nil {
+				return nil, err
+			}
+			impls = append(impls, protocolImpl{proto, name, fn, fields != nil})
+		default:
+			return nil, fmt.Errorf("invalid argument to %s: %s", form, Print(arg))
+		}
+	}
+	return impls, nil
+}
+
+// add method implementations to their protocols for a type
+func extendImpls(env *Env, t any, impls []protocolImpl) error {
+	for _, impl := range impls {
+		pv, err := impl.proto(env)
+		if err != nil {
+			return err
+		}
+		p, isProto := pv.(*protocol)
+		if !isProto {
+			return fmt.Errorf("%s is not a protocol", Print(pv))
+		}
+		if !p.hasMethod(impl.name) {
+			return fmt.Errorf("%s is not a method of protocol %s", impl.name, p.name)
+		}
+		fn, err := impl.fn(env)
+		if err != nil {
+			return err
+		}
+		if impl.fields {
+			fn = t.(*recordType).withFields(fn)
+		}
+		p.extend(t, impl.name, fn)
+	}
+	return nil
+}
+
+func (p *protocol) hasMethod(name Symbol) bool {
+	for _, method := range p.methods {
+		if method == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *protocol) extend(t any, name Symbol, fn any) {
+	methods, exists := p.impls[t]
+	if !exists {
+		methods = make(map[Symbol]any)
+		p.impls[t] = methods
+		if rt, isType := t.(reflect.Type); isType && rt.Kind() == reflect.Interface && rt != anyType {
+			p.ifaces = append(p.ifaces, rt)
+		}
+	}
+	methods[name] = fn
+}
+
+func (f *protocolFn) call(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of args (0) passed to protocol method: %s", f.name)
+	}
+	method, exists := f.proto.find(f.name, typeKey(args[0]))
+	if !exists {
+		return nil, fmt.Errorf("no implementation of method: %s of protocol: %s found for type: %s",
+			f.name, f.proto.name, printType(typeKey(args[0])))
+	}
+	return invoke(method, args)
+}
+
+// the implementation of a method for a type, then for an interface that it
+// implements, then for Object
+func (p *protocol) find(name Symbol, key any) (any, bool) {
+	if method, exists := p.impls[key][name]; exists {
+		return method, true
+	}
+	if t, isType := key.(reflect.Type); isType {
+		for _, iface := range p.ifaces {
+			if method, exists := p.impls[iface][name]; exists && t.Implements(iface) {
+				return method, true
+			}
+		}
+	}
+	method, exists := p.impls[anyType][name]
+	return method, exists
+}
+
+// (satisfies? Protocol val) is true if the type of val has been extended
+// with the protocol
+func satisfies(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to satisfies?", len(args))
+	}
+	p, isProto := args[0].(*protocol)
+	if !isProto {
+		return nil, fmt.Errorf("%s is not a protocol", Print(args[0]))
+	}
+	for _, method := range p.methods {
+		if _, exists := p.find(method, typeKey(args[1])); exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Types
+
+var (
+	fnType  = reflect.TypeOf(&procedure{})
+	anyType = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// the lisp names of the types of values, which protocols can be extended to
+var lispTypes = map[Symbol]reflect.Type{
+	"Int":        reflect.TypeOf(0),
+	"Float":      reflect.TypeOf(0.0),
+	"BigInt":     reflect.TypeOf((*big.Int)(nil)),
+	"Ratio":      reflect.TypeOf((*big.Rat)(nil)),
+	"BigDecimal": reflect.TypeOf((*big.Float)(nil)),
+	"String":     reflect.TypeOf(""),
+	"Char":       reflect.TypeOf('a'),
+	"Boolean":    reflect.TypeOf(true),
+	"Keyword":    reflect.TypeOf(Keyword("")),
+	"Symbol":     reflect.TypeOf(Symbol("")),
+	"List":       reflect.TypeOf(List{}),
+	"Vector":     reflect.TypeOf([]any{}),
+	"Map":        reflect.TypeOf(map[any]any{}),
+	"Set":        reflect.TypeOf(Set{}),
+	"Inst":       reflect.TypeOf(time.Time{}),
+	"UUID":       reflect.TypeOf(UUID{}),
+	"Var":        reflect.TypeOf((*Var)(nil)),
+	"Fn":         fnType,
+	"Object":     anyType,
+}
+
+// the type that protocols dispatch on, where all kinds of lisp function
+// share the Fn type
+func typeOf(val any) reflect.Type {
+	switch val.(type) {
+	case *procedure, *closure, primitive, tailPrimitive, *multiFn, *protocolFn:
+		return fnType
+	}
+	return reflect.TypeOf(val)
+}
+
+// the key that protocols dispatch on: the record type of a record, or else
+// the type of the value
+func typeKey(val any) any {
+	if r, isRecord := val.(*record); isRecord {
+		return r.rtype
+	}
+	if t := typeOf(val); t != nil {
+		return t
+	}
+	return nil
+}
+
+// (type val) is the type of a value, or nil for nil
+func typePrim(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to type", len(args))
+	}
+	return typeKey(args[0]), nil
+}
+
+func printType(key any) string {
+	var t reflect.Type
+	switch k := key.(type) {
+	case nil:
+		return "nil"
+	case *recordType:
+		return recordTag(k)
+	case reflect.Type:
+		t = k
+	}
+	for name, lt := range lispTypes {
+		if lt == t {
+			return string(name)
+		}
+	}
+	return t.String()
+}
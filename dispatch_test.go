@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const shapes = `
+	(defmulti area (fn [shape] (shape :kind)))
+	(defmethod area :square [s] (* (s :side) (s :side)))
+	(defmethod area :rect [r] (* (r :w) (r :h)))
+	(defmethod area :default [s] :unknown)
+`
+
+func TestMultimethods(t *testing.T) {
+	testEval(t, shapes+"(area {:kind :square :side 3})", 9)
+	testEval(t, shapes+"(area {:kind :rect :w 2 :h 5})", 10)
+	testEval(t, shapes+"(area {:kind :circle})", Keyword("unknown"))
+	testEval(t, shapes+"(defmethod area :square [s] 0) (area {:kind :square :side 3})", 0)
+	testEval(t, "(defmulti greet (fn [a b] [a b])) (defmethod greet [:en :formal] [a b] \"good day\") (greet :en :formal)", "good day")
+	testEval(t, "(defn f [] (defmulti m (fn [x] x)) (defmethod m 1 [x] :one)) (f) (m 1)", Keyword("one"))
+	testEval(t, "(defmulti m (fn [a b] (+ a b))) (defmethod m 3 [a b] :three) (apply m [1 2])", Keyword("three"))
+
+	testEvalError(t, "(defmulti m (fn [x] x)) (defmethod m 1 [x] :one) (m 2)")
+	testEvalError(t, "(defmethod nope 1 [x] x)")
+	testEvalError(t, "(def x 1) (defmethod x 1 [x] x)")
+	testEvalError(t, "(defmulti 1 (fn [x] x))")
+
+	_, err := readEval("(defmulti kind (fn [x] x)) (kind :circle)", NewEnv())
+	if err == nil || err.Error() != "no method in multimethod 'kind' for dispatch value: :circle" {
+		t.Errorf("Expected: no method error\nActual: %v\n", err)
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	testEval(t, "(derive :square :rect) (isa? :square :rect)", true)
+	testEval(t, "(derive :square :rect) (derive :rect :shape) (isa? :square :shape)", true)
+	testEval(t, "(derive :square :rect) (isa? :rect :square)", false)
+	testEval(t, "(isa? 1 1)", true)
+	testEval(t, "(derive :square :rect) (isa? [:square 1] [:rect 1])", true)
+	testEval(t, "(isa? [:square] [:rect 1])", false)
+	testEval(t, "(isa? (type (testpoint 1 2)) Stringer)", true)
+	testEval(t, "(isa? Int Stringer)", false)
+
+	testEval(t, "(apply derive [:square :rect]) (apply isa? [:square :rect])", true)
+	testEval(t, "(def is isa?) (derive :square :rect) [(is :square :rect) (is :circle :rect)]", []any{true, false})
+	testEvalError(t, "(derive :a :a)")
+	testEvalError(t, "(derive :a :b) (derive :b :a)")
+	testEvalError(t, "(derive [:a] :b)")
+	testEvalError(t, "(derive :a)")
+
+	// the most specific method wins
+	testEval(t, shapes+`
+		(derive :square :rect)
+		(derive :cube :square)
+		(defmethod area :rect [r] :rect)
+		(defmethod area :square [s] :square)
+		(area {:kind :cube})`, Keyword("square"))
+	testEvalError(t, shapes+`
+		(derive :tile :square)
+		(derive :tile :rect)
+		(area {:kind :tile})`)
+
+	// each environment has its own hierarchy
+	testEval(t, "(isa? :square :rect)", false)
+}
+
+const showable = `
+	(defprotocol Show
+		"things that can be shown"
+		(show [this] "show a value")
+		(show-with [this prefix]))
+	(extend-type Int Show
+		(show [n] (+ n 1))
+		(show-with [n prefix] [prefix n]))
+	(extend-type nil Show
+		(show [n] :nothing))
+`
+
+func TestProtocols(t *testing.T) {
+	testEval(t, showable+"(show 1)", 2)
+	testEval(t, showable+"(show-with 1 :n)", []any{Keyword("n"), 1})
+	testEval(t, showable+"(show nil)", Keyword("nothing"))
+	testEval(t, showable+"(extend-type Vector Show (show [v] (apply + v))) (show [1 2 3])", 6)
+	testEval(t, showable+"(extend-type Fn Show (show [f] (f))) (show (fn [] :called))", Keyword("called"))
+	testEval(t, showable+"(extend-type Object Show (show [x] :object)) [(show 1) (show :a)]", []any{2, Keyword("object")})
+	testEval(t, showable+"(satisfies? Show 1)", true)
+	testEval(t, showable+"(satisfies? Show :a)", false)
+
+	// go types, and interfaces that they implement
+	testEval(t, showable+"(extend-type TestPoint Show (show [p] (.Sum p))) (show (testpoint 1 2))", 3)
+	testEval(t, showable+"(extend-type Stringer Show (show [s] (.String s))) (show (testpoint 1 2))", "(1, 2)")
+	testEval(t, showable+`
+		(extend-type Stringer Show (show [s] :stringer))
+		(extend-type TestPoint Show (show [p] :point))
+		(show (testpoint 1 2))`, Keyword("point"))
+
+	testEvalError(t, showable+"(show)")
+	testEvalError(t, showable+"(extend-type Int Show (nope [n] n))")
+	testEvalError(t, showable+"(extend-type 1 Show (show [n] n))")
+	testEvalError(t, "(def Show 1) (extend-type Int Show (show [n] n))")
+	testEvalError(t, "(defprotocol P (m []))")
+	testEvalError(t, "(defprotocol P m)")
+
+	_, err := readEval(showable+"(show :a)", NewEnv())
+	if err == nil || err.Error() != "no implementation of method: show of protocol: Show found for type: Keyword" {
+		t.Errorf("Expected: no implementation error\nActual: %v\n", err)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	testEval(t, "(= (type 1) Int)", true)
+	testEval(t, "(= (type [1]) Vector)", true)
+	testEval(t, "(= (type (fn [x] x)) (type +) Fn)", true)
+	testEval(t, "(type nil)", nil)
+
+	for input, output := range map[string]string{
+		"Int":                       "#<type Int>",
+		"(type (testpoint 1 2))":    "#<type *main.testPoint>",
+		"(defprotocol P (m [x])) P": "#<protocol P>",
+		"(defprotocol P (m [x])) m": "#<fn m>",
+		"(defmulti m (fn [x] x)) m": "#<multifn m>",
+	} {
+		val, err := readEval(input, NewEnv())
+		if err != nil || Print(val) != output {
+			t.Errorf("\nExpected: %s\nActual: %s %v\n", output, Print(val), err)
+		}
+	}
+}
+
+func init() {
+	defaultEnv[Symbol("TestPoint")] = reflect.TypeOf(&testPoint{})
+	defaultEnv[Symbol("Stringer")] = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// a uuid read from a #uuid tagged literal
+type UUID [16]byte
+
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func parseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid uuid: %s", s)
+	}
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil {
+		return u, fmt.Errorf("invalid uuid: %s", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+func uuidReader(val any) (any, error) {
+	s, isStr := val.(string)
+	if !isStr {
+		return nil, fmt.Errorf("#uuid expects a string: %s", Print(val))
+	}
+	return parseUUID(s)
+}
+
+// the layouts accepted by #inst, from the most to the least precise
+var instLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func instReader(val any) (any, error) {
+	s, isStr := val.(string)
+	if !isStr {
+		return nil, fmt.Errorf("#inst expects a string: %s", Print(val))
+	}
+	for _, layout := range instLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			// instants in utc can be compared with == as map keys
+			return t.UTC(), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid timestamp: %s", s)
+}
+
+// read a single value from a string of edn without evaluating it:
+// (edn/read-string s)
+func ednReadString(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to edn/read-string", len(args))
+	}
+	s, isStr := args[0].(string)
+	if !isStr {
+		return nil, fmt.Errorf("edn/read-string expects a string: %s", Print(args[0]))
+	}
+
+	val, err := ednReader.Read(bufio.NewReader(strings.NewReader(s)))
+	if err == io.EOF {
+		return nil, nil
+	}
+	return val, err
+}
+
+// write a value as edn, failing for values that edn can't represent:
+// (edn/write val)
+func ednWrite(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to edn/write", len(args))
+	}
+	if err := checkEDN(args[0]); err != nil {
+		return nil, err
+	}
+	return Print(args[0]), nil
+}
+
+func checkEDN(val any) error {
+	switch t := val.(type) {
+	case nil, bool, int, float64, *big.Int, *big.Float, string, rune, Symbol, Keyword, time.Time, UUID:
+		return nil
+	case List:
+		return checkEDNSlice(t)
+	case []any:
+		return checkEDNSlice(t)
+	case map[any]any:
+		for k, v := range t {
+			if err := checkEDN(k); err != nil {
+				return err
+			}
+			if err := checkEDN(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Set:
+		for k := range t {
+			if err := checkEDN(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("can't write %s as edn", Print(val))
+}
+
+func checkEDNSlice(val []any) error {
+	for _, v := range val {
+		if err := checkEDN(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
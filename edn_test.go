@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEDNReadString(t *testing.T) {
+	testEval(t, `(edn/read-string "{:a [1 2.5] :ns/b #{\"c\"}}")`,
+		map[any]any{Keyword("a"): []any{1, 2.5}, Keyword("ns/b"): Set{"c": {}}})
+	testEval(t, `(edn/read-string "(+ 1 2)")`, List{Symbol("+"), 1, 2})
+	testEval(t, `(edn/read-string "#_ 1 2")`, 2)
+	testEval(t, `(edn/read-string "#inst \"2020-01-01T00:00:00Z\"")`, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	testEval(t, `(edn/read-string "")`, nil)
+	testEval(t, `(edn/read-string "; just a comment")`, nil)
+	testEvalError(t, `(edn/read-string "::a")`)
+	testEvalError(t, `(edn/read-string ":")`)
+	testEvalError(t, `(edn/read-string "#foo 1")`)
+	testEvalError(t, `(edn/read-string "[1")`)
+	testEvalError(t, `(edn/read-string "#{[1]}")`)
+	testEvalError(t, `(edn/read-string 1)`)
+	testEvalError(t, `(edn/read-string)`)
+}
+
+func TestEDNWrite(t *testing.T) {
+	testEval(t, "(edn/write [1 :a \"b\" \\c nil])", `[1 :a "b" \c nil]`)
+	testEval(t, "(edn/write (quote (a b)))", "(a b)")
+	testEval(t, "(edn/write #{:a})", "#{:a}")
+	testEval(t, `(edn/write (edn/read-string "#uuid \"f81d4fae-7dec-11d0-a765-00a0c91e6bf6\""))`,
+		`#uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bf6"`)
+	testEvalError(t, "(edn/write +)")
+	testEvalError(t, "(edn/write [(fn [] 1)])")
+	testEvalError(t, "(edn/write {:a (testpoint 1 2)})")
+	testEvalError(t, "(edn/write)")
+}
+
+func TestMapLiterals(t *testing.T) {
+	testEval(t, "{(quote a) 1 :b (+ 1 1)}", map[any]any{Symbol("a"): 1, Keyword("b"): 2})
+	testEval(t, "((fn [k] {k 1}) :a)", map[any]any{Keyword("a"): 1})
+	testEvalError(t, "((fn [k] {k 1}) [])")
+	testEvalError(t, "{[] 1}")
+	testEvalError(t, `(edn/read-string "{[1] 2}")`)
+}
+
+func TestSetLiterals(t *testing.T) {
+	testEval(t, "#{1 (+ 1 1)}", Set{1: {}, 2: {}})
+	testEval(t, "((fn [x] #{x :b}) :a)", Set{Keyword("a"): {}, Keyword("b"): {}})
+	testEval(t, "#{}", Set{})
+	testEvalError(t, "#{[] (quote x)}")
+	testEvalError(t, "#{(undefined)}")
+}
@@ -2,17 +2,71 @@ package main
 
 import "fmt"
 
+// An environment is either a map of global symbols or the frame of a
+// procedure call, whose locals are addressed by their slot
 type Env struct {
 	symbols map[Symbol]any
+	slots   []any
 	parent  *Env
+	// set on a global environment while EvalContext is running
+	eval *evalState
+	// the number of calls in progress on the go stack, on a global environment
+	depth int
+	// the parents of values related by derive, on a global environment
+	parents map[any][]any
+	// the capabilities of a sandbox, or nil for every one, and whether they
+	// include interop, on a global environment
+	allowed map[string]bool
+	interop bool
 }
 
+// a local that has been reserved by def but not defined yet
+type unboundVar struct{}
+
 func NewEnv() *Env {
-	return &Env{defaultEnv, nil}
+	symbols := make(map[Symbol]any, len(defaultEnv))
+	for sym, val := range defaultEnv {
+		symbols[sym] = val
+	}
+	return globalEnv(symbols, nil)
+}
+
+// builtins that use the global environment they are bound in, which are
+// created for each new environment
+type envBuiltin func(env *Env) any
+
+// create a global environment, binding its builtins that use it
+func globalEnv(symbols map[Symbol]any, allowed map[string]bool) *Env {
+	env := &Env{symbols: symbols, allowed: allowed, interop: allowed == nil || allowed["interop"]}
+	for sym, val := range symbols {
+		if builtin, isEnvBuiltin := val.(envBuiltin); isEnvBuiltin {
+			symbols[sym] = builtin(env)
+		}
+	}
+	return env
 }
 
 func ChildEnv(parent *Env) *Env {
-	return &Env{make(map[Symbol]any), parent}
+	return &Env{symbols: make(map[Symbol]any), parent: parent}
+}
+
+// create a procedure frame with args in the first slots and the
+// rest reserved for locals that haven't been defined yet
+func frameEnv(parent *Env, args []any, size int) *Env {
+	slots := make([]any, size)
+	copy(slots, args)
+	for i := len(args); i < size; i++ {
+		slots[i] = unboundVar{}
+	}
+	return &Env{slots: slots, parent: parent}
+}
+
+// the global environment at the top of e
+func (e *Env) root() *Env {
+	for e.parent != nil {
+		e = e.parent
+	}
+	return e
 }
 
 func (e *Env) Define(s Symbol, val any) {
@@ -25,7 +79,11 @@ func (e *Env) Find(s Symbol) (any, error) {
 		return f, nil
 	}
 	if e.parent == nil {
-		return nil, fmt.Errorf("unable to resolve symbol: %v in this context", s)
+		return nil, unresolved(s)
 	}
 	return e.parent.Find(s)
 }
+
+func unresolved(s Symbol) error {
+	return fmt.Errorf("unable to resolve symbol: %v in this context", s)
+}
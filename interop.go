@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Rewrite the interop forms (.Method obj args...) and (.-Field obj) into
+// calls to the reflection primitives (. "Method" obj args...) and
+// (.- "Field" obj), which are only bound when interop is allowed
+func expandInterop(form List) (List, bool) {
+	head, isSym := form[0].(Symbol)
+	if !isSym || len(head) < 2 || head[0] != '.' || head[1] == '.' {
+		return nil, false
+	}
+
+	if strings.HasPrefix(string(head), ".-") {
+		if len(head) < 3 {
+			return nil, false
+		}
+		return append(List{Symbol(".-"), string(head[2:])}, form[1:]...), true
+	}
+	return append(List{Symbol("."), string(head[1:])}, form[1:]...), true
+}
+
+// call a method on a go value: (.Method obj args...)
+func callMethod(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no method name passed to .")
+	}
+	name, isName := args[0].(string)
+	if !isName {
+		return nil, fmt.Errorf("method name must be a string: %s", Print(args[0]))
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("no target object passed to method: %s", name)
+	}
+	if args[1] == nil {
+		return nil, fmt.Errorf("can't call method %s on nil", name)
+	}
+
+	method := reflect.ValueOf(args[1]).MethodByName(name)
+	if !method.IsValid() {
+		return nil, fmt.Errorf("no method %s on %T", name, args[1])
+	}
+	return call(method.Interface(), args[2:])
+}
+
+// read a struct field on a go value: (.-Field obj)
+func getField(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no field name passed to .-")
+	}
+	name, isName := args[0].(string)
+	if !isName {
+		return nil, fmt.Errorf("field name must be a string: %s", Print(args[0]))
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to field access: %s", len(args)-1, name)
+	}
+
+	field, err := structField(args[1], name)
+	if err != nil {
+		return nil, err
+	}
+	return fromGo(field), nil
+}
+
+// set a struct field through a pointer: (set-field! obj :Field val)
+func setField(args []any) (any, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to set-field!", len(args))
+	}
+
+	name, isName := fieldName(args[1])
+	if !isName {
+		return nil, fmt.Errorf("field name must be a keyword, symbol or string: %s", Print(args[1]))
+	}
+
+	if reflect.ValueOf(args[0]).Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("fields can only be set through a pointer: %T", args[0])
+	}
+
+	field, err := structField(args[0], name)
+	if err != nil {
+		return nil, err
+	}
+	if !field.CanSet() {
+		return nil, fmt.Errorf("field %s on %T can't be set", name, args[0])
+	}
+
+	if args[2] == nil {
+		switch field.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+			field.Set(reflect.Zero(field.Type()))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't set field %s of type %v to nil", name, field.Type())
+	}
+
+	val, err := toGo(args[2], field.Type())
+	if err != nil {
+		return nil, fmt.Errorf("wrong value for field %s: %v", name, err)
+	}
+	field.Set(val)
+	return args[2], nil
+}
+
+// find an exported field on a struct or a pointer to a struct
+func structField(obj any, name string) (reflect.Value, error) {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("can't access field %s on %T", name, obj)
+	}
+
+	field := v.FieldByName(name)
+	if !field.IsValid() || !field.CanInterface() {
+		return reflect.Value{}, fmt.Errorf("no field %s on %T", name, obj)
+	}
+	return field, nil
+}
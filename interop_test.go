@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMethodCall(t *testing.T) {
+	testEval(t, "(.Sum (testpoint 1 2))", 3)
+	testEval(t, "(.String (testpoint 1 2))", "(1, 2)")
+	testEval(t, "(.String (.Move (testpoint 1 2) 10 20))", "(11, 22)")
+	testEval(t, `
+		(def sb (newbuilder))
+		(.WriteString sb "abc")
+		(.WriteString sb "def")
+		(.String sb)`, "abcdef")
+	testEval(t, "((fn [p] (.Sum p)) (testpoint 3 4))", 7)
+}
+
+func TestMethodCallErrors(t *testing.T) {
+	testEvalError(t, "(.Sum)")
+	testEvalError(t, "(.Sum nil)")
+	testEvalError(t, "(.Missing (testpoint 1 2))")
+	testEvalError(t, "(.Move (testpoint 1 2) 10)")
+	testEvalError(t, "(.Move (testpoint 1 2) 10 \"20\")")
+}
+
+func TestFieldAccess(t *testing.T) {
+	testEval(t, "(.-X (testpoint 1 2))", 1)
+	testEval(t, "(.-Tags (testpoint 1 2))", List{"a", "b"})
+	testEvalError(t, "(.-Z (testpoint 1 2))")
+	testEvalError(t, "(.-name (testpoint 1 2))")
+	testEvalError(t, "(.-X 5)")
+	testEvalError(t, "(.-X)")
+}
+
+func TestSetField(t *testing.T) {
+	testEval(t, `
+		(def p (testpoint 1 2))
+		(set-field! p :X 10)
+		(set-field! p (quote Y) 20)
+		(.Sum p)`, 30)
+	testEval(t, `
+		(def p (testpoint 1 2))
+		(set-field! p "Tags" nil)
+		(.-Tags p)`, List{})
+	testEvalError(t, "(set-field! (testpoint 1 2) :X \"a\")")
+	testEvalError(t, "(set-field! (testpoint 1 2) :X nil)")
+	testEvalError(t, "(set-field! (testpoint 1 2) :name \"a\")")
+	testEvalError(t, "(set-field! (.String (testpoint 1 2)) :X 1)")
+}
+
+type testPoint struct {
+	X, Y int
+	Tags []string
+	name string
+}
+
+func (p *testPoint) Move(dx, dy int) *testPoint {
+	p.X += dx
+	p.Y += dy
+	return p
+}
+
+func (p testPoint) Sum() int {
+	return p.X + p.Y
+}
+
+func (p testPoint) String() string {
+	return fmt.Sprintf("(%d, %d)", p.X, p.Y)
+}
+
+func init() {
+	defaultEnv[Symbol("testpoint")] = gofunc(func(x, y int) *testPoint {
+		return &testPoint{X: x, Y: y, Tags: []string{"a", "b"}}
+	})
+	defaultEnv[Symbol("newbuilder")] = gofunc(func() *strings.Builder {
+		return &strings.Builder{}
+	})
+}
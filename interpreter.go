@@ -1,194 +1,341 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"reflect"
 )
 
+// the builtins of every capability, which NewEnv starts from
 var defaultEnv map[Symbol]any
 
+// builtins grouped by what they give a script access to, so that a
+// sandbox can be built from the ones it allows (see NewSandboxEnv)
+var capabilities map[string]map[Symbol]any
+
 func init() {
-	defaultEnv = map[Symbol]any{
-		Symbol("+"):           primitive(add),
-		Symbol("-"):           primitive(sub),
-		Symbol("*"):           primitive(mul),
-		Symbol("/"):           primitive(div),
-		Symbol("="):           primitive(eq),
-		Symbol("<"):           primitive(lt),
-		Symbol("<="):          primitive(lte),
-		Symbol(">"):           primitive(gt),
-		Symbol(">="):          primitive(gte),
-		Symbol("exit"):        primitive(exit),
-		Symbol("quote"):       specialform(quote),
-		Symbol("do"):          specialform(do),
-		Symbol("def"):         specialform(def),
-		Symbol("fn"):          specialform(fn),
-		Symbol("defn"):        specialform(defn),
-		Symbol("if"):          specialform(ifprim),
-		Symbol("cond"):        specialform(cond),
-		Symbol("fmt.Println"): gofunc(fmt.Println),
-		Symbol("fmt.Printf"):  gofunc(fmt.Printf),
-		Symbol("marshal"):     gofunc(marshal),
+	capabilities = map[string]map[Symbol]any{
+		"core": {
+			Symbol("+"):              primitive(add),
+			Symbol("-"):              primitive(sub),
+			Symbol("*"):              primitive(mul),
+			Symbol("/"):              primitive(div),
+			Symbol("="):              primitive(eq),
+			Symbol("<"):              primitive(lt),
+			Symbol("<="):             primitive(lte),
+			Symbol(">"):              primitive(gt),
+			Symbol(">="):             primitive(gte),
+			Symbol("deref"):          primitive(deref),
+			Symbol("apply"):          tailPrimitive(applyPrim),
+			Symbol("trampoline"):     primitive(trampoline),
+			Symbol("quote"):          specialform(quote),
+			Symbol("var"):            specialform(varform),
+			Symbol("do"):             specialform(do),
+			Symbol("def"):            specialform(def),
+			Symbol("fn"):             specialform(fn),
+			Symbol("defn"):           specialform(defn),
+			Symbol("if"):             specialform(ifprim),
+			Symbol("cond"):           specialform(cond),
+			Symbol("loop"):           specialform(loopform),
+			Symbol("recur"):          specialform(recurform),
+			Symbol("defmulti"):       specialform(defmulti),
+			Symbol("defmethod"):      specialform(defmethod),
+			Symbol("derive"):         envBuiltin(derive),
+			Symbol("isa?"):           envBuiltin(isa),
+			Symbol("defprotocol"):    specialform(defprotocol),
+			Symbol("extend-type"):    specialform(extendType),
+			Symbol("defrecord"):      specialform(defrecord),
+			Symbol("get"):            envBuiltin(get),
+			Symbol("get-in"):         envBuiltin(getIn),
+			Symbol("assoc"):          primitive(assoc),
+			Symbol("assoc-in"):       envBuiltin(assocIn),
+			Symbol("update"):         envBuiltin(update),
+			Symbol("update-in"):      envBuiltin(updateIn),
+			Symbol("dissoc"):         primitive(dissoc),
+			Symbol("merge"):          primitive(merge),
+			Symbol("merge-with"):     primitive(mergeWithPrim),
+			Symbol("select-keys"):    envBuiltin(selectKeys),
+			Symbol("keys"):           primitive(keys),
+			Symbol("vals"):           primitive(vals),
+			Symbol("satisfies?"):     primitive(satisfies),
+			Symbol("type"):           primitive(typePrim),
+			Symbol("*data-readers*"): map[any]any{},
+		},
+		"exit": {
+			Symbol("exit"): primitive(exit),
+		},
+		"print": {
+			Symbol("fmt.Println"): gofunc(fmt.Println),
+			Symbol("fmt.Printf"):  gofunc(fmt.Printf),
+		},
+		"interop": {
+			Symbol("."):          primitive(callMethod),
+			Symbol(".-"):         primitive(getField),
+			Symbol("set-field!"): primitive(setField),
+			Symbol("->map"):      primitive(toMap),
+			Symbol("->struct"):   primitive(toStruct),
+		},
+		"json": {
+			Symbol("marshal"):       gofunc(marshal),
+			Symbol("json/write"):    primitive(jsonWrite),
+			Symbol("json/read"):     primitive(jsonRead),
+			Symbol("json/read-all"): primitive(jsonReadAll),
+			Symbol("json/each"):     primitive(jsonEachPrim),
+		},
+		"edn": {
+			Symbol("edn/read-string"): primitive(ednReadString),
+			Symbol("edn/write"):       primitive(ednWrite),
+		},
+		// go packages that use the filesystem or the clock can only be
+		// allowed in a sandbox along with these
+		"fs":    {},
+		"clock": {},
+	}
+	for name, t := range lispTypes {
+		capabilities["core"][name] = t
+	}
+
+	defaultEnv = make(map[Symbol]any)
+	for _, builtins := range capabilities {
+		for sym, val := range builtins {
+			defaultEnv[sym] = val
+		}
 	}
 }
 
 // primitives take pre-evaluated arguments
 type primitive func(args []any) (any, error)
 
-// special forms take unevaluated arguments and the env
-type specialform func(args []any, env *Env) (any, error)
+// primitives that end by calling a function, which they return along with
+// its args for the caller to make, so that the call can be a tail call
+type tailPrimitive func(args []any) (any, []any, error)
+
+// special forms are compiled from their unevaluated arguments
+type specialform func(args []any, sc *scope, tail bool) (compiled, error)
 
 // a wrapper around a go function
 type gofunc any
 
 // store a user defined function that can be applied later
 type procedure struct {
+	name   string
 	params []Symbol
-	body   []any
-	env    *Env
+	// the last param collects any remaining args
+	variadic bool
+	body     compiled
+	env      *Env
+	// the number of frame slots needed for params and locals
+	size int
 }
 
 // a return value that indicates that we should perform tail call optimization
 type tailcall struct {
-	nextVal any
-	env     *Env
+	proc *procedure
+	args []any
+}
+
+// a return value from recur in tail position, which runs the body of the
+// enclosing fn or loop again with new values for its params
+type recurcall struct {
+	args []any
 }
 
-// Evaluate an expression using tail call optimization
+// evaluate using the bytecode vm instead of analyzed closures
+var useVM bool
+
+// Evaluate an expression by analyzing it and then running the result
 func Eval(val any, env *Env) (any, error) {
-	var err error
-	for {
-		val, err = performEval(val, env)
-		if err != nil {
-			return nil, err
+	// the forms of a top level do are evaluated one at a time, so that each
+	// is analyzed with the definitions made by the ones before it
+	if forms, isDo := topLevelDo(val, env); isDo {
+		var ret any
+		for _, form := range forms {
+			var err error
+			if ret, err = Eval(form, env); err != nil {
+				return nil, err
+			}
 		}
+		return ret, nil
+	}
 
-		tail, isTail := val.(tailcall)
-		if !isTail {
-			return val, err
-		}
-		val = tail.nextVal
-		env = tail.env
+	if useVM {
+		return vmEval(val, env)
+	}
+	c, err := analyze(val, newScope(env), false)
+	if err != nil {
+		return nil, err
 	}
+	return c(env)
 }
 
-// Evaluate an expression (returns tailcall if tco is needed)
-func performEval(val any, env *Env) (any, error) {
-	switch t := val.(type) {
-	case Symbol:
-		return env.Find(t)
-	case []any:
-		return evalSlice(t, env)
-	case map[any]any:
-		return evalMap(t, env)
-	case List:
-		if len(t) == 0 {
-			return t, nil
-		}
+// the forms of (do forms...), if that is what val is
+func topLevelDo(val any, env *Env) ([]any, bool) {
+	l, isList := val.(List)
+	if !isList || len(l) == 0 {
+		return nil, false
+	}
+	head, isSym := l[0].(Symbol)
+	if !isSym {
+		return nil, false
+	}
+	spec, err := env.Find(head)
+	if err != nil {
+		return nil, false
+	}
+	s, isSpec := spec.(specialform)
+	return l[1:], isSpec && sameFunc(s, do)
+}
+
+// apply a function value of any kind to pre-evaluated args
+func invoke(front any, args []any) (any, error) {
+	return invokeIn(front, args, true)
+}
 
-		front, err := Eval(t[0], env)
+// apply a function value, where maps, vectors, keywords and structs look up
+// fields of go structs only with interop
+func invokeIn(front any, args []any, interop bool) (any, error) {
+	switch f := front.(type) {
+	case primitive:
+		return f(args)
+	case tailPrimitive:
+		next, nextArgs, err := f(args)
 		if err != nil {
 			return nil, err
 		}
-
-		spec, isSpec := front.(specialform)
-		if isSpec {
-			return spec(t[1:], env)
-		}
-
-		args, err := evalSlice(t[1:], env)
+		return invokeIn(next, nextArgs, interop)
+	case *procedure:
+		return apply(f, args)
+	case *closure:
+		return runClosure(f, args)
+	case *multiFn:
+		return f.call(args)
+	case *protocolFn:
+		return f.call(args)
+	case *Var:
+		val, err := f.Deref()
 		if err != nil {
 			return nil, err
 		}
+		return invokeIn(val, args, interop)
+	case map[any]any, []any, *record:
+		return accessMap(f, args, interop)
+	case Keyword:
+		return keywordLookup(f, args, interop)
+	case Set:
+		return setLookup(f, args)
+	}
 
-		prim, isPrim := front.(primitive)
-		if isPrim {
-			return prim(args)
-		}
+	if reflect.ValueOf(front).Kind() == reflect.Func {
+		return call(front, args)
+	}
+	if _, isStruct := asStruct(front); isStruct {
+		return accessMap(front, args, interop)
+	}
 
-		proc, isProc := front.(procedure)
-		if isProc {
-			return apply(proc, args)
-		}
+	return nil, fmt.Errorf("invalid proc: %s", Print(front))
+}
 
-		mp, isMap := front.(map[any]any)
-		if isMap {
-			return accessMap(mp, args)
+// access values in a (potentially nested) map, vector or struct
+func accessMap(val any, args []any, interop bool) (any, error) {
+	ret := val
+	for _, arg := range args {
+		access, exists, err := lookup(ret, arg, interop)
+		if err != nil {
+			return nil, err
 		}
-
-		fun, isFun := front.(gofunc)
-		if isFun {
-			return call(fun, args)
+		if !exists {
+			return nil, fmt.Errorf("value does not exist in map: %v ", arg)
 		}
+		ret = access
+	}
+	return ret, nil
+}
 
-		return nil, fmt.Errorf("invalid proc: %v", front)
-	default:
-		return t, nil
+// a keyword looks itself up in a map, record, struct or set, returning nil
+// or the default when it is missing or can't be looked up: (:a m default)
+func keywordLookup(k Keyword, args []any, interop bool) (any, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to keyword: %s", len(args), Print(k))
+	}
+	var notFound any
+	if len(args) == 2 {
+		notFound = args[1]
+	}
+	val, exists, err := getKey(args[0], k, interop)
+	if err != nil {
+		return nil, err
 	}
+	if !exists {
+		return notFound, nil
+	}
+	return val, nil
 }
 
-// eval all elements in a slice
-func evalSlice(val []any, env *Env) ([]any, error) {
-	arr := make([]any, len(val))
-	for i, v := range val {
-		res, err := Eval(v, env)
-		if err != nil {
-			return nil, err
-		}
-		arr[i] = res
+// a set returns its arg if it is a member, otherwise nil
+func setLookup(s Set, args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to set", len(args))
 	}
-	return arr, nil
+	if !isHashable(args[0]) {
+		return nil, nil
+	}
+	if _, exists := s[args[0]]; !exists {
+		return nil, nil
+	}
+	return args[0], nil
 }
 
-// eval all elements in a map
-func evalMap(val map[any]any, env *Env) (map[any]any, error) {
-	ret := make(map[any]any, len(val))
-	for k, v := range val {
-		evalK, err := Eval(k, env)
-		if err != nil {
+// apply a procedure, looping on tail calls so that they don't grow the stack
+func apply(proc *procedure, args []any) (any, error) {
+	root := proc.env.root()
+	if err := root.enter(); err != nil {
+		return nil, err
+	}
+	defer root.leave()
+
+	for {
+		if err := charge(root, 1, int64(proc.size)*slotSize); err != nil {
 			return nil, err
 		}
-		evalV, err := Eval(v, env)
+		bound, err := bindArgs(len(proc.params), proc.variadic, args)
 		if err != nil {
 			return nil, err
 		}
-		ret[evalK] = evalV
-	}
-	return ret, nil
-}
 
-// access values in a (potentially nested) map
-func accessMap(val map[any]any, args []any) (any, error) {
-	var ret any
-	ret = val
-	for _, arg := range args {
-		asmap, ismap := ret.(map[any]any)
-		if !ismap {
-			return nil, fmt.Errorf("trying to access nested value that isn't a map: %v", arg)
+		val, err := runBody(root, proc, bound)
+		if err != nil {
+			return nil, err
 		}
 
-		access, exists := asmap[arg]
-		if !exists {
-			return nil, fmt.Errorf("value does not exist in map: %v ", arg)
+		tail, isTail := val.(tailcall)
+		if !isTail {
+			return val, nil
 		}
-		ret = access
+		proc = tail.proc
+		args = tail.args
 	}
-	return ret, nil
 }
 
-func apply(proc procedure, args []any) (any, error) {
-	if len(args) != len(proc.params) {
-		return nil, fmt.Errorf("wrong number of args (%d) passed to procedure", len(args))
-	}
+// run the body of a procedure in a new frame, running it again in another
+// frame for each recur
+func runBody(root *Env, proc *procedure, bound []any) (any, error) {
+	for {
+		val, err := proc.body(frameEnv(proc.env, bound, proc.size))
+		if err != nil {
+			return nil, err
+		}
 
-	child := ChildEnv(proc.env)
-	for i, arg := range args {
-		child.Define(proc.params[i], arg)
+		next, isRecur := val.(recurcall)
+		if !isRecur {
+			return val, nil
+		}
+		if err := charge(root, 1, int64(proc.size)*slotSize); err != nil {
+			return nil, err
+		}
+		bound = next.args
 	}
-
-	return do(proc.body, child)
 }
 
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -201,37 +348,34 @@ func call(fun gofunc, args []any) (any, error) {
 		return nil, fmt.Errorf("wrong number of args (%d) passed to procedure", len(args))
 	}
 
+	gc := &goCall{running: true}
 	in := make([]reflect.Value, len(args))
 	for i, arg := range args {
-		if !isArgTypeValid(f.Type(), reflect.TypeOf(arg), i) {
-			return nil, fmt.Errorf("wrong arg type (%v) passed to procedure", reflect.TypeOf(arg))
+		val, err := toGoFor(arg, paramType(f.Type(), i), gc)
+		if err != nil {
+			return nil, fmt.Errorf("wrong type for arg %d passed to procedure: %v", i+1, err)
 		}
-
-		in[i] = reflect.ValueOf(arg)
+		in[i] = val
 	}
 
 	result := f.Call(in)
+	err := gc.finish()
+	if err != nil {
+		return nil, err
+	}
 
 	if len(result) < 1 {
 		return nil, nil
 	}
 
 	out := make(List, 0)
-	var err error
 	for _, res := range result {
 		if res.Type() == errorType {
 			if !res.IsNil() {
 				err = res.Interface().(error)
 			}
-		} else if res.Kind() == reflect.Slice {
-			// If the procedure returns a slice, convert it to a List
-			arr := make(List, res.Len())
-			for i := 0; i < res.Len(); i++ {
-				arr[i] = res.Index(i).Interface()
-			}
-			out = append(out, arr)
 		} else {
-			out = append(out, res.Interface())
+			out = append(out, fromGo(res))
 		}
 	}
 
@@ -244,6 +388,19 @@ func call(fun gofunc, args []any) (any, error) {
 	return out, err
 }
 
+// convert a value returned from go into a lisp value
+func fromGo(res reflect.Value) any {
+	if res.Kind() == reflect.Slice {
+		// If the procedure returns a slice, convert it to a List
+		arr := make(List, res.Len())
+		for i := 0; i < res.Len(); i++ {
+			arr[i] = res.Index(i).Interface()
+		}
+		return arr
+	}
+	return res.Interface()
+}
+
 func isArgLenValid(funcT reflect.Type, length int) bool {
 	if !funcT.IsVariadic() {
 		// Non variadic functions need matching argument lengths
@@ -255,160 +412,132 @@ func isArgLenValid(funcT reflect.Type, length int) bool {
 	}
 }
 
-func isArgTypeValid(funcT, argT reflect.Type, argIdx int) bool {
-	if !funcT.IsVariadic() {
-		// Non variadic, check that arg is assignable to param at idx
-		return argT.AssignableTo(funcT.In(argIdx))
-	} else {
-		if argIdx < funcT.NumIn()-1 {
-			// Variadic, but before the last param
-			return argT.AssignableTo(funcT.In(argIdx))
-		} else {
-			// Variadic, a parameter in the last array
-			return argT.AssignableTo(funcT.In(funcT.NumIn() - 1).Elem())
-		}
+// the type of the parameter that an arg at idx is passed to
+func paramType(funcT reflect.Type, argIdx int) reflect.Type {
+	if funcT.IsVariadic() && argIdx >= funcT.NumIn()-1 {
+		// Variadic, a parameter in the last array
+		return funcT.In(funcT.NumIn() - 1).Elem()
 	}
+	return funcT.In(argIdx)
 }
 
 // Primitives
 
 func add(args []any) (any, error) {
-	return agg(args,
-		func(r, x int) int {
-			return r + x
+	return agg(args, arith{
+		int: func(r, x int) (int, bool) {
+			sum := r + x
+			return sum, (r^sum)&(x^sum) >= 0
 		},
-		func(r, x float64) float64 {
-			return r + x
-		})
+		float:      func(r, x float64) float64 { return r + x },
+		bigInt:     (*big.Int).Add,
+		ratio:      (*big.Rat).Add,
+		bigDecimal: (*big.Float).Add,
+	})
 }
 
 func sub(args []any) (any, error) {
 	if len(args) == 1 {
 		args = append([]any{0}, args...)
 	}
-	return agg(args,
-		func(r, x int) int {
-			return r - x
+	return agg(args, arith{
+		int: func(r, x int) (int, bool) {
+			diff := r - x
+			return diff, (r^x)&(r^diff) >= 0
 		},
-		func(r, x float64) float64 {
-			return r - x
-		})
+		float:      func(r, x float64) float64 { return r - x },
+		bigInt:     (*big.Int).Sub,
+		ratio:      (*big.Rat).Sub,
+		bigDecimal: (*big.Float).Sub,
+	})
 }
 
 func mul(args []any) (any, error) {
-	return agg(args,
-		func(r, x int) int {
-			return r * x
+	return agg(args, arith{
+		int: func(r, x int) (int, bool) {
+			if r == 0 || x == 0 {
+				return 0, true
+			}
+			prod := r * x
+			return prod, prod/x == r && !(x == -1 && r == math.MinInt)
 		},
-		func(r, x float64) float64 {
-			return r * x
-		})
+		float:      func(r, x float64) float64 { return r * x },
+		bigInt:     (*big.Int).Mul,
+		ratio:      (*big.Rat).Mul,
+		bigDecimal: (*big.Float).Mul,
+	})
 }
 
+// integer division truncates, and only division by a zero integer, ratio
+// or big decimal fails, since float division follows IEEE 754
 func div(args []any) (any, error) {
-	return agg(args,
-		func(r, x int) int {
-			return r / x
+	return agg(args, arith{
+		int: func(r, x int) (int, bool) {
+			return r / x, !(x == -1 && r == math.MinInt)
 		},
-		func(r, x float64) float64 {
-			return r / x
-		})
+		float:      func(r, x float64) float64 { return r / x },
+		bigInt:     (*big.Int).Quo,
+		ratio:      (*big.Rat).Quo,
+		bigDecimal: (*big.Float).Quo,
+		div:        true,
+	})
 }
 
-func agg(args []any, accumInt func(int, int) int, accumFloat func(float64, float64) float64) (any, error) {
+func agg(args []any, op arith) (any, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("wrong number of args (%d) passed to procedure", len(args))
 	}
+	if err := checkNumbers(args); err != nil {
+		return nil, err
+	}
 
 	ret := args[0]
 	for i := 1; i < len(args); i++ {
-		ri, rIsInt := ret.(int)
-		rf, rIsFloat := ret.(float64)
-
-		ai, aIsInt := args[i].(int)
-		af, aIsFloat := args[i].(float64)
-
-		if rIsInt && aIsInt {
-			ret = accumInt(ri, ai)
-		} else if rIsInt && aIsFloat {
-			ret = accumFloat(float64(ri), af)
-		} else if rIsFloat && aIsInt {
-			ret = accumFloat(rf, float64(ai))
-		} else if rIsFloat && aIsFloat {
-			ret = accumFloat(rf, af)
-		} else {
-			return nil, fmt.Errorf("invalid operand: %v", args[i])
+		var err error
+		if ret, err = op.apply(ret, args[i]); err != nil {
+			return nil, err
 		}
 	}
 	return ret, nil
 }
 
+func checkNumbers(args []any) error {
+	for _, arg := range args {
+		if _, isNum := numRank(arg); !isNum {
+			return fmt.Errorf("invalid operand: %s", Print(arg))
+		}
+	}
+	return nil
+}
+
 func lt(args []any) (any, error) {
-	return order(args,
-		func(r, x int) bool {
-			return r < x
-		},
-		func(r, x float64) bool {
-			return r < x
-		})
+	return order(args, func(c int) bool { return c < 0 })
 }
 
 func lte(args []any) (any, error) {
-	return order(args,
-		func(r, x int) bool {
-			return r <= x
-		},
-		func(r, x float64) bool {
-			return r <= x
-		})
+	return order(args, func(c int) bool { return c <= 0 })
 }
 
 func gt(args []any) (any, error) {
-	return order(args,
-		func(r, x int) bool {
-			return r > x
-		},
-		func(r, x float64) bool {
-			return r > x
-		})
+	return order(args, func(c int) bool { return c > 0 })
 }
 
 func gte(args []any) (any, error) {
-	return order(args,
-		func(r, x int) bool {
-			return r >= x
-		},
-		func(r, x float64) bool {
-			return r >= x
-		})
+	return order(args, func(c int) bool { return c >= 0 })
 }
 
-func order(args []any, orderInt func(int, int) bool, orderFloat func(float64, float64) bool) (any, error) {
+// whether each pair of args is in order, by the result of comparing them
+func order(args []any, inOrder func(c int) bool) (any, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("wrong number of args (%d) passed to procedure", len(args))
 	}
+	if err := checkNumbers(args); err != nil {
+		return nil, err
+	}
 
 	for i := 1; i < len(args); i++ {
-		ri, rIsInt := args[i-1].(int)
-		rf, rIsFloat := args[i-1].(float64)
-
-		ai, aIsInt := args[i].(int)
-		af, aIsFloat := args[i].(float64)
-
-		var ret bool
-		if rIsInt && aIsInt {
-			ret = orderInt(ri, ai)
-		} else if rIsInt && aIsFloat {
-			ret = orderFloat(float64(ri), af)
-		} else if rIsFloat && aIsInt {
-			ret = orderFloat(rf, float64(ai))
-		} else if rIsFloat && aIsFloat {
-			ret = orderFloat(rf, af)
-		} else {
-			return nil, fmt.Errorf("invalid operand: %v", args[i])
-		}
-
-		if !ret {
+		c, comparable := compareNums(args[i-1], args[i])
+		if !comparable || !inOrder(c) {
 			return false, nil
 		}
 	}
@@ -422,6 +551,13 @@ func eq(args []any) (any, error) {
 
 	compare := args[0]
 	for i := 1; i < len(args); i++ {
+		if isInteger(compare) && isInteger(args[i]) {
+			// ints and big ints are equal by value
+			if c, _ := compareNums(compare, args[i]); c != 0 {
+				return false, nil
+			}
+			continue
+		}
 		if reflect.TypeOf(compare) != reflect.TypeOf(args[i]) {
 			return false, nil
 		}
@@ -432,6 +568,62 @@ func eq(args []any) (any, error) {
 	return true, nil
 }
 
+func deref(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to deref", len(args))
+	}
+	ref, isRef := args[0].(derefer)
+	if !isRef {
+		return nil, fmt.Errorf("can't deref %s", Print(args[0]))
+	}
+	return ref.Deref()
+}
+
+// call a function with args, where the last arg is a collection of the
+// rest of the args: (apply f a b [c d])
+func applyPrim(args []any) (any, []any, error) {
+	if len(args) < 2 {
+		return nil, nil, fmt.Errorf("wrong number of args (%d) passed to apply", len(args))
+	}
+
+	var rest []any
+	switch t := args[len(args)-1].(type) {
+	case nil:
+	case List:
+		rest = t
+	case []any:
+		rest = t
+	default:
+		return nil, nil, fmt.Errorf("last argument to apply must be a list or vector: %s", Print(t))
+	}
+
+	spread := make([]any, 0, len(args)-2+len(rest))
+	spread = append(spread, args[1:len(args)-1]...)
+	spread = append(spread, rest...)
+	return args[0], spread, nil
+}
+
+// call f with args, then keep calling the result with no args for as long
+// as it is a lisp function: (trampoline f args...)
+func trampoline(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of args (0) passed to trampoline")
+	}
+	val, err := invoke(args[0], args[1:])
+	for err == nil && isLispFn(val) {
+		val, err = invoke(val, nil)
+	}
+	return val, err
+}
+
+func isLispFn(val any) bool {
+	switch val.(type) {
+	case *procedure, *closure, *multiFn, *protocolFn:
+		return true
+	}
+	return false
+}
+
 func exit(args []any) (any, error) {
 	if len(args) == 0 {
 		os.Exit(0)
@@ -448,148 +640,445 @@ func exit(args []any) (any, error) {
 
 // Special Forms
 
-func quote(args []any, env *Env) (any, error) {
-	if len(args) != 1 {
-		return nil, fmt.Errorf("wrong number of args (%d) passed to quote", len(args))
+func quote(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkQuote(args); err != nil {
+		return nil, err
 	}
-	return args[0], nil
+	return constant(args[0]), nil
 }
 
-func do(args []any, env *Env) (any, error) {
-	if len(args) == 0 {
-		return nil, nil
+func checkQuote(args []any) error {
+	if len(args) != 1 {
+		return fmt.Errorf("wrong number of args (%d) passed to quote", len(args))
 	}
+	return nil
+}
 
-	// evaluate all args except for the last one
-	_, err := evalSlice(args[:len(args)-1], env)
-	if err != nil {
+// a reference to a global, which finds its current value when it is used
+type Var struct {
+	name Symbol
+	env  *Env
+}
+
+func (v *Var) Deref() (any, error) {
+	return v.env.Find(v.name)
+}
+
+// something that holds a value which can be read with deref
+type derefer interface {
+	Deref() (any, error)
+}
+
+// (var x) refers to the global x, rather than to its value
+func varform(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkArity("var", args, 1, 1); err != nil {
 		return nil, err
 	}
+	sym, isSym := args[0].(Symbol)
+	if !isSym {
+		return nil, fmt.Errorf("var expects a symbol: %s", Print(args[0]))
+	}
+	if _, _, isLocal := sc.resolve(sym); isLocal {
+		return nil, fmt.Errorf("can't take the var of a local: %s", sym)
+	}
 
-	// return an indicator that we should eval the
-	// last argument using tail call optimization
-	return tailcall{
-		nextVal: args[len(args)-1],
-		env:     env,
+	depth := sc.depth()
+	return func(env *Env) (any, error) {
+		for i := 0; i < depth; i++ {
+			env = env.parent
+		}
+		if _, err := env.Find(sym); err != nil {
+			return nil, err
+		}
+		return &Var{sym, env}, nil
 	}, nil
 }
 
-func def(args []any, env *Env) (any, error) {
-	if len(args) > 2 {
-		return nil, fmt.Errorf("too many arguments to def")
+func do(args []any, sc *scope, tail bool) (compiled, error) {
+	return analyzeBody(args, sc, tail)
+}
+
+func def(args []any, sc *scope, tail bool) (compiled, error) {
+	sym, err := parseDef(args)
+	if err != nil {
+		return nil, err
 	}
-	if len(args) < 2 {
-		return nil, fmt.Errorf("too few arguments to def")
+
+	set := definer(sym, sc)
+	val, err := analyze(args[1], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	return define(sym, set, val), nil
+}
+
+func parseDef(args []any) (Symbol, error) {
+	if err := checkArity("def", args, 2, 2); err != nil {
+		return "", err
 	}
 
 	sym, isSym := args[0].(Symbol)
 
 	if !isSym {
-		return nil, fmt.Errorf("first argument to def must be a Symbol")
+		return "", fmt.Errorf("first argument to def must be a Symbol")
+	}
+	return sym, nil
+}
+
+// inside of a procedure body def creates a local, otherwise a global
+// (loops have frames, but def in a loop defines in the scope around it)
+func definer(sym Symbol, sc *scope) func(env *Env, val any) {
+	depth := 0
+	for ; sc.loop; sc = sc.parent {
+		depth++
+	}
+
+	if sc.parent == nil {
+		return func(env *Env, val any) {
+			for i := 0; i < depth; i++ {
+				env = env.parent
+			}
+			env.Define(sym, val)
+		}
 	}
+	slot := sc.declare(sym)
+	return func(env *Env, val any) {
+		for i := 0; i < depth; i++ {
+			env = env.parent
+		}
+		env.slots[slot] = val
+	}
+}
 
-	evaled, err := Eval(args[1], env)
+func define(sym Symbol, set func(env *Env, val any), val compiled) compiled {
+	return func(env *Env) (any, error) {
+		evaled, err := val(env)
+		if err != nil {
+			return nil, err
+		}
+		set(env, evaled)
+		return sym, nil
+	}
+}
+
+func fn(args []any, sc *scope, tail bool) (compiled, error) {
+	return analyzeFn("", args, sc)
+}
+
+func analyzeFn(name string, args []any, sc *scope) (compiled, error) {
+	symbols, variadic, err := parseParams(args)
 	if err != nil {
 		return nil, err
 	}
 
-	env.Define(sym, evaled)
-	return sym, nil
+	fsc := childScope(sc, symbols)
+	for _, sym := range collectDefs(args[1:], fsc) {
+		fsc.declare(sym)
+	}
+
+	body, err := analyzeBody(args[1:], fsc, true)
+	if err != nil {
+		return nil, err
+	}
+	size := len(fsc.names)
+
+	return func(env *Env) (any, error) {
+		return &procedure{
+			name:     name,
+			params:   symbols,
+			variadic: variadic,
+			body:     body,
+			env:      env,
+			size:     size,
+		}, nil
+	}, nil
 }
 
-func fn(args []any, env *Env) (any, error) {
-	if len(args) < 1 {
-		return nil, fmt.Errorf("too few arguments to fn")
+// the parameter list of a fn form
+func parseParams(args []any) ([]Symbol, bool, error) {
+	if err := checkArity("fn", args, 1, -1); err != nil {
+		return nil, false, err
 	}
 
 	vect, isVect := args[0].([]any)
 	if !isVect {
-		return nil, fmt.Errorf("first argument to fn must be a []any")
+		return nil, false, fmt.Errorf("first argument to fn must be a []any")
 	}
 
-	symbols := make([]Symbol, len(vect))
+	symbols := make([]Symbol, 0, len(vect))
+	variadic := false
 	for i, v := range vect {
 		sym, isSym := v.(Symbol)
 		if !isSym {
-			return nil, fmt.Errorf("first argument to fn must be a []any of Symbols")
+			return nil, false, fmt.Errorf("first argument to fn must be a []any of Symbols")
 		}
-		symbols[i] = sym
+		if sym == "&" {
+			// the rest of the args are collected into the next param
+			if variadic || i != len(vect)-2 {
+				return nil, false, fmt.Errorf("& must be followed by a single rest param")
+			}
+			variadic = true
+			continue
+		}
+		symbols = append(symbols, sym)
 	}
+	return symbols, variadic, nil
+}
 
-	return procedure{
-		params: symbols,
-		body:   args[1:],
-		env:    env,
-	}, nil
+// the args that fill the params of a procedure, with any args past the
+// last param collected into a list for a variadic procedure
+func bindArgs(nparams int, variadic bool, args []any) ([]any, error) {
+	if !variadic {
+		if len(args) != nparams {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to procedure", len(args))
+		}
+		return args, nil
+	}
+	if len(args) < nparams-1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to procedure", len(args))
+	}
+	bound := make([]any, nparams)
+	copy(bound, args[:nparams-1])
+	if len(args) >= nparams {
+		bound[nparams-1] = append(List{}, args[nparams-1:]...)
+	}
+	return bound, nil
 }
 
-func defn(args []any, env *Env) (any, error) {
-	if len(args) < 2 {
-		return nil, fmt.Errorf("too few arguments to defn")
+func defn(args []any, sc *scope, tail bool) (compiled, error) {
+	sym, err := parseDefn(args)
+	if err != nil {
+		return nil, err
 	}
 
-	proc, err := fn(args[1:], env)
+	set := definer(sym, sc)
+	proc, err := analyzeFn(string(sym), args[1:], sc)
 	if err != nil {
 		return nil, err
 	}
-	return def([]any{args[0], proc}, env)
+	return define(sym, set, proc), nil
 }
 
-func ifprim(args []any, env *Env) (any, error) {
-	if len(args) < 2 {
-		return nil, fmt.Errorf("too few arguments to if")
+func parseDefn(args []any) (Symbol, error) {
+	if err := checkArity("defn", args, 2, -1); err != nil {
+		return "", err
 	}
-	if len(args) > 3 {
-		return nil, fmt.Errorf("too many arguments to if")
+
+	sym, isSym := args[0].(Symbol)
+	if !isSym {
+		return "", fmt.Errorf("first argument to defn must be a Symbol")
 	}
+	return sym, nil
+}
 
-	cond, err := Eval(args[0], env)
+// (loop [a 1 b 2] body...) binds its locals in order in a new frame, and
+// runs the body again with new values each time it ends in recur
+func loopform(args []any, sc *scope, tail bool) (compiled, error) {
+	names, inits, err := parseLoop(args)
 	if err != nil {
 		return nil, err
 	}
 
-	if isTruthy(cond) {
-		return tailcall{args[1], env}, nil
+	lsc := childScope(sc, nil)
+	lsc.loop = true
+	values := make([]compiled, len(inits))
+	for i, init := range inits {
+		// each binding can refer to the ones before it
+		if values[i], err = analyze(init, lsc, false); err != nil {
+			return nil, err
+		}
+		lsc.names = append(lsc.names, names[i])
+	}
+	lsc.nparams = len(names)
+
+	body, err := analyzeBody(args[1:], lsc, true)
+	if err != nil {
+		return nil, err
+	}
+	size := len(lsc.names)
+
+	return func(env *Env) (any, error) {
+		frame := frameEnv(env, nil, size)
+		for i, value := range values {
+			val, err := value(frame)
+			if err != nil {
+				return nil, err
+			}
+			frame.slots[i] = val
+		}
+
+		for {
+			val, err := body(frame)
+			if err != nil {
+				return nil, err
+			}
+
+			switch t := val.(type) {
+			case recurcall:
+				if err := charge(env, 1, int64(size)*slotSize); err != nil {
+					return nil, err
+				}
+				frame = frameEnv(env, t.args, size)
+			case tailcall:
+				// the body is analyzed as a tail, but the loop might not be
+				if !tail {
+					return apply(t.proc, t.args)
+				}
+				return t, nil
+			default:
+				return val, nil
+			}
+		}
+	}, nil
+}
+
+func parseLoop(args []any) ([]Symbol, []any, error) {
+	if err := checkArity("loop", args, 1, -1); err != nil {
+		return nil, nil, err
+	}
+
+	bindings, isVect := args[0].([]any)
+	if !isVect {
+		return nil, nil, fmt.Errorf("first argument to loop must be a []any of bindings")
+	}
+	if len(bindings)%2 != 0 {
+		return nil, nil, fmt.Errorf("loop requires an even number of forms in its bindings")
+	}
+
+	names := make([]Symbol, 0, len(bindings)/2)
+	inits := make([]any, 0, len(bindings)/2)
+	for i := 0; i < len(bindings); i += 2 {
+		sym, isSym := bindings[i].(Symbol)
+		if !isSym {
+			return nil, nil, fmt.Errorf("loop binding must be a Symbol: %s", Print(bindings[i]))
+		}
+		names = append(names, sym)
+		inits = append(inits, bindings[i+1])
+	}
+	return names, inits, nil
+}
+
+// (recur args...) runs the enclosing fn or loop again, which can only be
+// done from a tail position so that it doesn't grow the stack
+func recurform(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkRecur(args, tail, sc.parent != nil, sc.nparams); err != nil {
+		return nil, err
+	}
+	vals, err := analyzeSlice(args, sc)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (any, error) {
+		next, err := runSlice(vals, env)
+		if err != nil {
+			return nil, err
+		}
+		return recurcall{next}, nil
+	}, nil
+}
+
+func checkRecur(args []any, tail, hasTarget bool, nparams int) error {
+	if !hasTarget {
+		return fmt.Errorf("recur can only be used inside of fn or loop")
+	}
+	if !tail {
+		return fmt.Errorf("recur can only be used in tail position")
+	}
+	if len(args) != nparams {
+		return fmt.Errorf("wrong number of args (%d) passed to recur, expected %d", len(args), nparams)
+	}
+	return nil
+}
+
+func ifprim(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkArity("if", args, 2, 3); err != nil {
+		return nil, err
+	}
+
+	cond, err := analyze(args[0], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	then, err := analyze(args[1], sc, tail)
+	if err != nil {
+		return nil, err
 	}
 
 	// else
+	otherwise := constant(nil)
 	if len(args) == 3 {
-		return tailcall{args[2], env}, nil
+		otherwise, err = analyze(args[2], sc, tail)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil, nil
+	return func(env *Env) (any, error) {
+		val, err := cond(env)
+		if err != nil {
+			return nil, err
+		}
+		if isTruthy(val) {
+			return then(env)
+		}
+		return otherwise(env)
+	}, nil
 }
 
 var elsekw = Keyword("else")
 
-func cond(args []any, env *Env) (any, error) {
-	if len(args)%2 != 0 {
-		return nil, fmt.Errorf("cond must have an even number of arguments: %d", len(args))
+func cond(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkCond(args); err != nil {
+		return nil, err
 	}
 
-	var elseExpr any
-
+	tests := make([]compiled, 0, len(args)/2)
+	exprs := make([]compiled, 0, len(args)/2)
 	for i := 0; i < len(args); i += 2 {
-		cond, err := Eval(args[i], env)
+		test, err := analyze(args[i], sc, false)
+		if err != nil {
+			return nil, err
+		}
+		expr, err := analyze(args[i+1], sc, tail)
 		if err != nil {
 			return nil, err
 		}
+		tests = append(tests, test)
+		exprs = append(exprs, expr)
+	}
 
-		if cond == elsekw {
-			elseExpr = args[i+1]
-			continue
+	return func(env *Env) (any, error) {
+		var elseExpr compiled
+
+		for i, test := range tests {
+			cond, err := test(env)
+			if err != nil {
+				return nil, err
+			}
+
+			if cond == elsekw {
+				elseExpr = exprs[i]
+				continue
+			}
+
+			if isTruthy(cond) {
+				return exprs[i](env)
+			}
 		}
 
-		if isTruthy(cond) {
-			return tailcall{args[i+1], env}, nil
+		if elseExpr != nil {
+			return elseExpr(env)
 		}
-	}
 
-	if elseExpr != nil {
-		return tailcall{elseExpr, env}, nil
-	}
+		return nil, nil
+	}, nil
+}
 
-	return nil, nil
+func checkCond(args []any) error {
+	if len(args)%2 != 0 {
+		return fmt.Errorf("cond must have an even number of arguments: %d", len(args))
+	}
+	return nil
 }
 
 func isTruthy(val any) bool {
@@ -601,9 +1090,5 @@ func isTruthy(val any) bool {
 }
 
 func marshal(val interface{}) (string, error) {
-	barr, err := json.Marshal(val)
-	if err != nil {
-		return "", err
-	}
-	return string(barr), err
+	return encodeJSON(val, false)
 }
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"errors"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
@@ -17,6 +19,64 @@ func TestArithmetic(t *testing.T) {
 	testEval(t, "(/ (- (+ 515 (* 87 311)) 302) 27)", 1010)
 	testEval(t, "(* -3 6)", -18)
 	testEval(t, "(/ (- (+ 515 (* -87 311)) 296) 27)", -994)
+	testEval(t, "(/ 1.0 0)", math.Inf(1))
+	testEval(t, "(/ 1 0.0)", math.Inf(1))
+	testEval(t, "(/ -4 2.0 0)", math.Inf(-1))
+	testEvalError(t, "(/ 4 2 0)")
+	testEvalError(t, "(/)")
+}
+
+func TestNumericTower(t *testing.T) {
+	testEval(t, "(+ 1/2 1)", big.NewRat(3, 2))
+	testEval(t, "(+ 1/2 1/2)", 1)
+	testEval(t, "(* 2 3/4)", big.NewRat(3, 2))
+	testEval(t, "(/ 1/2 2)", big.NewRat(1, 4))
+	testEval(t, "(+ 1N 1)", big.NewInt(2))
+	testEval(t, "(- 9223372036854775808 1)", big.NewInt(math.MaxInt64))
+	minInt := big.NewInt(math.MinInt64)
+	testEval(t, "(+ 9223372036854775807 1)", new(big.Int).Neg(minInt))
+	testEval(t, "(- -9223372036854775808 1)", new(big.Int).Sub(minInt, big.NewInt(1)))
+	testEval(t, "(- -9223372036854775808)", new(big.Int).Neg(minInt))
+	testEval(t, "(* 4611686018427387904 4)", new(big.Int).Lsh(big.NewInt(1), 64))
+	testEval(t, "(* -1 -9223372036854775808)", new(big.Int).Neg(minInt))
+	testEval(t, "(/ -9223372036854775808 -1)", new(big.Int).Neg(minInt))
+	testEval(t, "(* 3037000499 3037000499)", 9223372030926249001)
+	testEval(t, "(+ -9223372036854775807 -1)", math.MinInt64)
+	testEval(t, "(* 1/2 2N)", 1)
+	testEval(t, "(/ 7N 2)", big.NewInt(3))
+	testEval(t, "(+ 1.5M 1)", big.NewFloat(2.5))
+	testEval(t, "(+ 1.5M 1/2)", big.NewFloat(2))
+	testEval(t, "(+ 1/2 0.25)", 0.75)
+	testEval(t, "(+ 1.5M 0.5)", 2.0)
+	testEval(t, "(- 1/2)", big.NewRat(-1, 2))
+	testEvalError(t, "(/ 1/2 0)")
+	testEvalError(t, "(/ 1N 0)")
+	testEvalError(t, "(/ 1.5M 0)")
+	testEval(t, "(/ 1/2 0.0)", math.Inf(1))
+
+	testEval(t, "(< 1/2 1)", true)
+	testEval(t, "(< 1 1/2)", false)
+	testEval(t, "(<= 1N 1 2.0 5/2 3M)", true)
+	testEval(t, "(> 9223372036854775808 1)", true)
+	testEval(t, "(< 1 ##NaN)", false)
+	testEval(t, "(>= ##NaN 1)", false)
+
+	testEval(t, "(= 1N 1)", true)
+	testEval(t, "(= 1 1N 1)", true)
+	testEval(t, "(= 2N 1)", false)
+	testEval(t, "(= 1/2 1/2)", true)
+	testEval(t, "(= 1 1.0)", false)
+
+	for input, message := range map[string]string{
+		"(+ 1/2 :a)":  "invalid operand: :a",
+		"(+ :a 1)":    "invalid operand: :a",
+		"(< 1 \"a\")": `invalid operand: "a"`,
+	} {
+		_, err := readEval(input, NewEnv())
+		if err == nil || err.Error() != message {
+			t.Errorf("\nExpected: %s\nActual: %v\n", message, err)
+		}
+	}
 }
 
 func TestEmpty(t *testing.T) {
@@ -32,6 +92,17 @@ func TestCollections(t *testing.T) {
 	testEval(t, "(quote (\"a\" (+ 7 8)))", List{"a", List{Symbol("+"), 7, 8}})
 }
 
+func TestUnhashableLiterals(t *testing.T) {
+	// keys and items are only known to be hashable once they are evaluated
+	testEval(t, "{(quote a) 1 :b (+ 1 1)}", map[any]any{Symbol("a"): 1, Keyword("b"): 2})
+	testEval(t, "#{(quote a) (+ 1 1)}", Set{Symbol("a"): {}, 2: {}})
+	testEvalError(t, "{[1] 2}")
+	testEvalError(t, "{(vector 1) 2}")
+	testEvalError(t, "((fn [k] {k 1}) [1])")
+	testEvalError(t, "#{[1]}")
+	testEvalError(t, "((fn [x] #{x}) {:a 1})")
+}
+
 func TestEq(t *testing.T) {
 	testEval(t, "(= 1 2)", false)
 	testEval(t, "(= 1 1)", true)
@@ -41,6 +112,11 @@ func TestEq(t *testing.T) {
 	testEval(t, "(= [1 2] [1 2])", true)
 	testEval(t, "(= {1 2 3 4} {1 2 3 5})", false)
 	testEval(t, "(= {1 2 3 4} {1 2 3 4})", true)
+	testEval(t, "(defn f [] 1) (= f f)", true)
+	testEval(t, "(defn f [] 1) (def g f) (= f g)", true)
+	testEval(t, "(defn f [] 1) (= f (fn [] 1))", false)
+	testEval(t, "(defn mk [] (fn [] 1)) (= (mk) (mk))", false)
+	testEval(t, "(= + +)", true)
 }
 
 func TestOrder(t *testing.T) {
@@ -115,6 +191,40 @@ func TestMap(t *testing.T) {
 	testEval(t, `
 		(def nested { :a "blah" :nmap { :na 42 :nb 43}})
 		(nested :nmap :nb)`, 43)
+	testEval(t, `({:a [1 {:b 2}]} :a 1 :b)`, 2)
+	testEvalError(t, `({:a 1} :b)`)
+	testEvalError(t, `({:a 1} [1])`)
+	testEvalError(t, `({:a 1} {:b 2})`)
+}
+
+func TestInvocableValues(t *testing.T) {
+	testEval(t, `(:a {:a 1})`, 1)
+	testEval(t, `(:b {:a 1})`, nil)
+	testEval(t, `(:b {:a 1} 2)`, 2)
+	testEval(t, `(:a nil)`, nil)
+	testEval(t, `(:a nil 3)`, 3)
+	testEval(t, `(:X (testpoint 1 2))`, 1)
+	testEval(t, `(defrecord Point [x y]) (:y (->Point 1 2))`, 2)
+	testEval(t, `(defn get-a [m] (:a m)) (get-a {:a 5})`, 5)
+	testEvalError(t, `(:a)`)
+	testEval(t, `(:a 1)`, nil)
+	testEval(t, `(:a 1 :none)`, Keyword("none"))
+	testEval(t, `(:a [1 2] :none)`, Keyword("none"))
+	testEval(t, `(:a #{:a})`, Keyword("a"))
+	testEvalError(t, `(:a {} 1 2)`)
+
+	testEval(t, `([10 20 30] 1)`, 20)
+	testEval(t, `([[1 2] [3 4]] 1 0)`, 3)
+	testEval(t, `(def v [10 20]) (v 0)`, 10)
+	testEvalError(t, `([10 20 30] 3)`)
+	testEvalError(t, `([10 20 30] -1)`)
+	testEvalError(t, `([10 20 30] :a)`)
+
+	testEval(t, `(#{1 2 3} 2)`, 2)
+	testEval(t, `(#{1 2 3} 4)`, nil)
+	testEval(t, `(#{:a} :a)`, Keyword("a"))
+	testEval(t, `(#{1} [1])`, nil)
+	testEvalError(t, `(#{1 2 3})`)
 }
 
 func TestFib(t *testing.T) {
@@ -162,6 +272,111 @@ func TestReflectionError(t *testing.T) {
 	testEval(t, "(testerr3 1 2 \"\")", List{1, 2})
 }
 
+func TestVariadic(t *testing.T) {
+	testEval(t, "((fn [& xs] xs) 1 2 3)", List{1, 2, 3})
+	testEval(t, "((fn [& xs] xs))", nil)
+	testEval(t, "((fn [a & xs] [a xs]) 1)", []any{1, nil})
+	testEval(t, "((fn [a & xs] [a xs]) 1 2)", []any{1, List{2}})
+	testEval(t, "(defn f [a b & more] (if more (f (+ a b) 0) [a b])) (f 1 2 3)", []any{3, 0})
+	testEvalError(t, "((fn [a b & xs] a) 1)")
+	testEvalError(t, "(fn [a &] a)")
+	testEvalError(t, "(fn [& a b] a)")
+	testEvalError(t, "(fn [& a & b] a)")
+}
+
+func TestLoop(t *testing.T) {
+	testEval(t, "(loop [i 0 acc 1] (if (= i 5) acc (recur (+ i 1) (* acc 2))))", 32)
+	testEval(t, "(loop [a 1 b (+ a 1)] [a b])", []any{1, 2})
+	testEval(t, "(loop [] 7)", 7)
+	testEval(t, "(+ 1 (loop [i 0] (if (< i 100000) (recur (+ i 1)) i)))", 100001)
+	testEval(t, "(defn f [n] (loop [i n] (if (> i 0) (recur (- i 1)) :done))) (f 100000)", Keyword("done"))
+	testEval(t, "((fn [x] [(loop [x 1] x) x]) 2)", []any{1, 2})
+	testEval(t, `
+		(defn g [x] (* x 10))
+		(+ 1 (loop [i 0] (if (< i 3) (recur (+ i 1)) (g i))))`, 31)
+	testEval(t, `
+		(loop [i 0 f (fn [] [])]
+			(if (< i 3)
+				(recur (+ i 1) (fn [] [i (f)]))
+				(f)))`, []any{2, []any{1, []any{0, []any{}}}})
+	testEval(t, "(loop [i 0] (def x i) (if (< i 2) (recur (+ i 1)) x))", 2)
+	testEval(t, "(defn h [] (loop [i 0] (def y i) (if (< i 2) (recur (+ i 1)) y))) (h)", 2)
+	testEval(t, "(loop [i 0] (cond (= i 3) i :else (recur (+ i 1))))", 3)
+	testEvalError(t, "(loop [i] i)")
+	testEvalError(t, "(loop [1 2] 1)")
+	testEvalError(t, "(loop i 1)")
+}
+
+func TestRecur(t *testing.T) {
+	testEval(t, "(defn count-up [i n] (if (< i n) (recur (+ i 1) n) i)) (count-up 0 100000)", 100000)
+	testEval(t, "((fn [a & more] (if more (recur (+ a 1) nil) a)) 1 2 3)", 2)
+	testEval(t, "(defn f [x] (do (def y (* x 2)) (if (> y 100) y (recur y)))) (f 1)", 128)
+
+	// recur is checked when it is defined, not when it runs
+	testEvalError(t, "(defn f [x] (+ 1 (recur x)))")
+	testEvalError(t, "(defn f [x] (recur x) x)")
+	testEvalError(t, "(defn f [x] (recur x 1))")
+	testEvalError(t, "(defn f [x] (if (recur x) 1 2))")
+	testEvalError(t, "(loop [i 0] (+ 1 (recur i)))")
+	testEvalError(t, "(loop [i 0] [(recur i)])")
+	testEvalError(t, "(recur 1)")
+	testEvalError(t, "(defn f [x] (loop [] (recur x)))")
+}
+
+func TestApply(t *testing.T) {
+	testEval(t, "(apply + [1 2 3])", 6)
+	testEval(t, "(apply + 1 2 '(3 4))", 10)
+	testEval(t, "(apply + 5 nil)", 5)
+	testEval(t, "(apply (fn [& xs] xs) 1 [])", List{1})
+	testEval(t, "(apply apply [+ [1 2]])", 3)
+	testEvalError(t, "(apply +)")
+	testEvalError(t, "(apply + 1)")
+	testEvalError(t, "(apply 1 [])")
+
+	// apply in tail position doesn't grow the stack
+	testEval(t, `
+		(defn ev? [n] (if (= n 0) true (apply od? [(- n 1)])))
+		(defn od? [n] (if (= n 0) false (apply ev? (- n 1) nil)))
+		(ev? 100001)`, false)
+}
+
+func TestTrampoline(t *testing.T) {
+	testEval(t, `
+		(defn ev? [n] (if (= n 0) true #(od? (- n 1))))
+		(defn od? [n] (if (= n 0) false #(ev? (- n 1))))
+		(trampoline ev? 100000)`, true)
+	testEval(t, "(trampoline + 1 2)", 3)
+	testEval(t, "(trampoline (fn [] (fn [] :done)))", Keyword("done"))
+	testEvalError(t, "(trampoline)")
+}
+
+func TestShorthandForms(t *testing.T) {
+	testEval(t, "'a", Symbol("a"))
+	testEval(t, "'(+ 1 2)", List{Symbol("+"), 1, 2})
+	testEval(t, "(#(+ % 1) 2)", 3)
+	testEval(t, "(#(- %2 %1) 1 5)", 4)
+	testEval(t, "(#(do [%1 %&]) 1 2 3)", []any{1, List{2, 3}})
+	testEval(t, "(#(do [%3]) 1 2 3)", []any{3})
+	testEval(t, "(#(do 5))", 5)
+	testEval(t, "((fn [f] (f 10)) #(* % %))", 100)
+	testEvalError(t, "(#(+ % 1))")
+	testEvalError(t, "(#(do [%2]) 1)")
+}
+
+func TestVars(t *testing.T) {
+	testEval(t, "(def x 1) @#'x", 1)
+	testEval(t, "(def x 1) (def v #'x) (def x 2) (deref v)", 2)
+	testEval(t, "(defn f [] 1) (def v (var f)) (defn f [] 2) (v)", 2)
+	testEval(t, "((fn [] (= @#'+ +)))", true)
+	testEval(t, "(= + -)", false)
+	testEvalError(t, "#'undefined")
+	testEvalError(t, "((fn [x] #'x) 1)")
+	testEvalError(t, "(var 1)")
+	testEvalError(t, "(var)")
+	testEvalError(t, "@1")
+	testEvalError(t, "(deref)")
+}
+
 func TestError(t *testing.T) {
 	testEvalError(t, "(abc 1 2 3)")
 	testEvalError(t, "((fn [x y] (+ y x)) 10 7 8)")
@@ -207,7 +422,7 @@ func readEval(input string, env *Env) (val any, err error) {
 			break
 		}
 		if rerr != nil {
-			return "", err
+			return "", rerr
 		}
 
 		val, err = Eval(rval, env)
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// convert lisp data into values that encoding/json can write, where
+// keywords and symbols are written as their names
+func toJSON(val any) (any, error) {
+	switch t := val.(type) {
+	case Keyword:
+		return string(t), nil
+	case Symbol:
+		return string(t), nil
+	case rune:
+		return string(t), nil
+	case float64:
+		if math.IsInf(t, 0) || math.IsNaN(t) {
+			return nil, fmt.Errorf("can't write %s as json", Print(t))
+		}
+		return t, nil
+	case UUID:
+		return t.String(), nil
+	case Set:
+		return toJSONSlice(setItems(t))
+	case List:
+		return toJSONSlice(t)
+	case []any:
+		return toJSONSlice(t)
+	case *record:
+		return toJSON(t.vals)
+	case map[any]any:
+		ret := make(map[string]any, len(t))
+		for k, v := range t {
+			key, err := jsonKey(k)
+			if err != nil {
+				return nil, err
+			}
+			val, err := toJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			ret[key] = val
+		}
+		return ret, nil
+	}
+	switch val.(type) {
+	case *procedure, *closure, *multiFn, *protocolFn:
+		return nil, fmt.Errorf("can't write %s as json", Print(val))
+	}
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Func, reflect.Chan:
+		return nil, fmt.Errorf("can't write %s as json", Print(val))
+	}
+	return val, nil
+}
+
+func toJSONSlice(val []any) ([]any, error) {
+	ret := make([]any, len(val))
+	for i, v := range val {
+		c, err := toJSON(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = c
+	}
+	return ret, nil
+}
+
+// json object keys have to be strings
+func jsonKey(key any) (string, error) {
+	switch t := key.(type) {
+	case string:
+		return t, nil
+	case Keyword:
+		return string(t), nil
+	case Symbol:
+		return string(t), nil
+	case int, float64, bool:
+		return fmt.Sprint(t), nil
+	}
+	return "", fmt.Errorf("can't write %s as a json key", Print(key))
+}
+
+// convert a value decoded by encoding/json into lisp data
+func fromJSON(val any, keywordize bool) any {
+	switch t := val.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil && i >= math.MinInt && i <= math.MaxInt {
+			return int(i)
+		}
+		f, _ := t.Float64()
+		return f
+	case []any:
+		for i, v := range t {
+			t[i] = fromJSON(v, keywordize)
+		}
+		return t
+	case map[string]any:
+		ret := make(map[any]any, len(t))
+		for k, v := range t {
+			var key any = k
+			if keywordize {
+				key = Keyword(k)
+			}
+			ret[key] = fromJSON(v, keywordize)
+		}
+		return ret
+	}
+	return val
+}
+
+// trailing keyword options passed to a primitive, e.g. :pretty true
+func parseOptions(name string, args []any, allowed ...Keyword) (map[Keyword]any, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("options passed to %s must be pairs", name)
+	}
+	opts := make(map[Keyword]any)
+	for i := 0; i < len(args); i += 2 {
+		kw, isKw := args[i].(Keyword)
+		if !isKw {
+			return nil, fmt.Errorf("invalid option passed to %s: %s", name, Print(args[i]))
+		}
+		found := false
+		for _, a := range allowed {
+			found = found || a == kw
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown option passed to %s: %s", name, Print(kw))
+		}
+		opts[kw] = args[i+1]
+	}
+	return opts, nil
+}
+
+func encodeJSON(val any, pretty bool) (string, error) {
+	conv, err := toJSON(val)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if pretty {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(conv); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// write lisp data as a json string: (json/write val :pretty true)
+func jsonWrite(args []any) (any, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to json/write", len(args))
+	}
+	opts, err := parseOptions("json/write", args[1:], "pretty")
+	if err != nil {
+		return nil, err
+	}
+	return encodeJSON(args[0], isTruthy(opts["pretty"]))
+}
+
+// a json decoder for a string or a go io.Reader
+func jsonDecoder(name string, args []any) (*json.Decoder, bool, error) {
+	if len(args) < 1 {
+		return nil, false, fmt.Errorf("wrong number of args (%d) passed to %s", len(args), name)
+	}
+	opts, err := parseOptions(name, args[1:], "keywordize")
+	if err != nil {
+		return nil, false, err
+	}
+
+	var r io.Reader
+	switch t := args[0].(type) {
+	case string:
+		r = strings.NewReader(t)
+	case io.Reader:
+		r = t
+	default:
+		return nil, false, fmt.Errorf("%s expects a string or reader: %s", name, Print(args[0]))
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec, isTruthy(opts["keywordize"]), nil
+}
+
+// read a single json value: (json/read str :keywordize true)
+func jsonRead(args []any) (any, error) {
+	dec, keywordize, err := jsonDecoder("json/read", args)
+	if err != nil {
+		return nil, err
+	}
+	var val any
+	if err := dec.Decode(&val); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after json value")
+	}
+	return fromJSON(val, keywordize), nil
+}
+
+// read every json value in a stream of json lines:
+// (json/read-all str :keywordize true)
+func jsonReadAll(args []any) (any, error) {
+	ret := List{}
+	_, err := jsonEach("json/read-all", args, func(val any) error {
+		ret = append(ret, val)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// call a function with each json value as it is read from a stream of json
+// lines: (json/each f reader :keywordize true)
+func jsonEachPrim(args []any) (any, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to json/each", len(args))
+	}
+	f := args[0]
+	return jsonEach("json/each", args[1:], func(val any) error {
+		_, err := invoke(f, []any{val})
+		return err
+	})
+}
+
+func jsonEach(name string, args []any, f func(val any) error) (any, error) {
+	dec, keywordize, err := jsonDecoder(name, args)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var val any
+		err := dec.Decode(&val)
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := f(fromJSON(val, keywordize)); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestJSONWrite(t *testing.T) {
+	testEval(t, "(json/write {:a 1})", `{"a":1}`)
+	testEval(t, `(json/write {"a" [1 2.5 "x"] :b nil})`, `{"a":[1,2.5,"x"],"b":null}`)
+	testEval(t, "(json/write (quote (1 2)))", "[1,2]")
+	testEval(t, "(json/write [:kw (quote sym) \\c true])", `["kw","sym","c",true]`)
+	testEval(t, "(json/write {1 {:nested [{}]}})", `{"1":{"nested":[{}]}}`)
+	testEval(t, `(json/write "<a&b>")`, `"<a&b>"`)
+	testEval(t, "(json/write {:a [1]} :pretty true)", "{\n  \"a\": [\n    1\n  ]\n}")
+	testEval(t, "(json/write (testconfig))", `{"name":"server","port":8080,"hosts":["a","b"],"limits":{"conns":10},"tls":{"cert":"cert.pem","key":""},"Level":"debug"}`)
+	testEval(t, "(marshal {:a 1})", `{"a":1}`)
+	testEvalError(t, "(json/write)")
+	testEvalError(t, "(json/write {[1] 1})")
+	testEvalError(t, "(json/write (fn [] 1))")
+	testEvalError(t, "(json/write +)")
+	testEvalError(t, "(json/write 1 :pretty)")
+	testEvalError(t, "(json/write 1 :indent true)")
+}
+
+func TestJSONRead(t *testing.T) {
+	testEval(t, `(json/read "{\"a\": 1}")`, map[any]any{"a": 1})
+	testEval(t, `(json/read "{\"a\": [1, 2.5]}" :keywordize true)`, map[any]any{Keyword("a"): []any{1, 2.5}})
+	testEval(t, `(json/read "[true, null, \"x\"]")`, []any{true, nil, "x"})
+	testEval(t, `(json/read "1e3")`, 1000.0)
+	testEval(t, `(json/read "123456789012345678901234567890")`, 123456789012345678901234567890.0)
+	testEval(t, `((json/read "{\"a\": {\"b\": 2}}" :keywordize true) :a :b)`, 2)
+	testEvalError(t, `(json/read "{")`)
+	testEvalError(t, `(json/read "1 2")`)
+	testEvalError(t, `(json/read 1)`)
+}
+
+func TestJSONStream(t *testing.T) {
+	testEval(t, `(json/read-all "{\"a\": 1}\n{\"a\": 2}\n")`, List{map[any]any{"a": 1}, map[any]any{"a": 2}})
+	testEval(t, `(json/read-all "")`, List{})
+	testEval(t, `(json/each (fn [x] x) "1\n2")`, nil)
+	testEvalError(t, `(json/read-all "1\n{")`)
+	testEvalError(t, `(json/each (fn [x] (undefined x)) "1\n2")`)
+	testEvalError(t, `(json/each (fn [x] x))`)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	testEval(t, `(json/read (json/write {:a [1 "b" {:c 2.5}]}) :keywordize true)`,
+		map[any]any{Keyword("a"): []any{1, "b", map[any]any{Keyword("c"): 2.5}}})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Limits bound the work that an evaluation can do, where zero is unlimited
+type Limits struct {
+	// procedure calls, including tail calls
+	Steps int64
+	// an estimate of the bytes allocated for call frames and collections
+	Alloc int64
+	// how deeply procedure calls can nest, instead of MaxDepth
+	Depth int
+}
+
+// The maximum depth of nested procedure calls, unless limited otherwise.
+// The vm keeps its call frames on the heap, so it can be given a much
+// larger depth than the default evaluator, which recurses on the go stack.
+var MaxDepth = 10000
+
+// A StackOverflowError is returned when procedure calls nest deeper than
+// the maximum depth, e.g. from unbounded recursion
+type StackOverflowError struct {
+	Depth int
+}
+
+func (e *StackOverflowError) Error() string {
+	return fmt.Sprintf("stack overflow: calls nested deeper than %d", e.Depth)
+}
+
+type limitsKey struct{}
+
+// Attach limits to a context, for EvalContext
+func WithLimits(ctx context.Context, limits Limits) context.Context {
+	return context.WithValue(ctx, limitsKey{}, limits)
+}
+
+var (
+	ErrStepLimit  = errors.New("step limit exceeded")
+	ErrAllocLimit = errors.New("allocation limit exceeded")
+)
+
+// A LimitError stops an evaluation that was cancelled or went past its
+// limits. Err is ErrStepLimit, ErrAllocLimit or the error of the context.
+type LimitError struct {
+	Err          error
+	Steps, Alloc int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("evaluation stopped after %d steps: %v", e.Steps, e.Err)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// the bytes estimated for each value in a frame or collection
+const slotSize = 16
+
+// the progress of an evaluation started by EvalContext
+type evalState struct {
+	done         <-chan struct{}
+	ctx          context.Context
+	limits       Limits
+	steps, alloc int64
+	// an evaluation that this one is running inside of
+	parent *evalState
+}
+
+// Evaluate an expression until it finishes or ctx is done, within any
+// limits attached to ctx with WithLimits
+func EvalContext(ctx context.Context, val any, env *Env) (any, error) {
+	root := env.root()
+	state := &evalState{done: ctx.Done(), ctx: ctx, parent: root.eval}
+	state.limits, _ = ctx.Value(limitsKey{}).(Limits)
+
+	root.eval = state
+	defer func() {
+		root.eval = state.parent
+	}()
+
+	if err := state.charge(0, 0); err != nil {
+		return nil, err
+	}
+	return Eval(val, env)
+}
+
+// count steps and allocated bytes against the evaluations running in env,
+// failing once one has been cancelled or gone past its limits
+func charge(env *Env, steps, alloc int64) error {
+	for s := env.root().eval; s != nil; s = s.parent {
+		if err := s.charge(steps, alloc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count a call that nests on the go stack of a global environment,
+// failing past the maximum depth
+func (e *Env) enter() error {
+	if max := e.maxDepth(); e.depth >= max {
+		return &StackOverflowError{max}
+	}
+	e.depth++
+	return nil
+}
+
+func (e *Env) leave() {
+	e.depth--
+}
+
+// the depth limit of the innermost evaluation that sets one
+func (e *Env) maxDepth() int {
+	for s := e.eval; s != nil; s = s.parent {
+		if s.limits.Depth > 0 {
+			return s.limits.Depth
+		}
+	}
+	return MaxDepth
+}
+
+func (s *evalState) charge(steps, alloc int64) error {
+	s.steps += steps
+	s.alloc += alloc
+
+	select {
+	case <-s.done:
+		return s.stop(s.ctx.Err())
+	default:
+	}
+	if s.limits.Steps > 0 && s.steps > s.limits.Steps {
+		return s.stop(ErrStepLimit)
+	}
+	if s.limits.Alloc > 0 && s.alloc > s.limits.Alloc {
+		return s.stop(ErrAllocLimit)
+	}
+	return nil
+}
+
+func (s *evalState) stop(err error) error {
+	return &LimitError{err, s.steps, s.alloc}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const infiniteLoop = `
+	(defn spin [n] (spin (+ n 1)))
+	(spin 0)`
+
+func TestEvalContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := readEvalContext(ctx, infiniteLoop, NewEnv())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected: deadline exceeded\nActual: %v\n", err)
+	}
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Steps == 0 {
+		t.Errorf("Expected: LimitError with steps\nActual: %v\n", err)
+	}
+}
+
+func TestEvalContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := readEvalContext(ctx, "(+ 1 2)", NewEnv())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected: cancelled\nActual: %v\n", err)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	ctx := WithLimits(context.Background(), Limits{Steps: 1000})
+	_, err := readEvalContext(ctx, infiniteLoop, NewEnv())
+	var lerr *LimitError
+	if !errors.Is(err, ErrStepLimit) || !errors.As(err, &lerr) || lerr.Steps != 1001 {
+		t.Errorf("Expected: step limit after 1001 steps\nActual: %v\n", err)
+	}
+
+	_, err = readEvalContext(ctx, "(loop [i 0] (recur (+ i 1)))", NewEnv())
+	if !errors.Is(err, ErrStepLimit) {
+		t.Errorf("Expected: step limit for a loop\nActual: %v\n", err)
+	}
+	_, err = readEvalContext(ctx, "((fn [i] (recur (+ i 1))) 0)", NewEnv())
+	if !errors.Is(err, ErrStepLimit) {
+		t.Errorf("Expected: step limit for recur\nActual: %v\n", err)
+	}
+
+	val, err := readEvalContext(ctx, "(defn sq [x] (* x x)) (sq (sq 3))", NewEnv())
+	if err != nil || val != 81 {
+		t.Errorf("\nExpected: 81\nActual: %v %v\n", val, err)
+	}
+}
+
+func TestAllocLimit(t *testing.T) {
+	ctx := WithLimits(context.Background(), Limits{Alloc: 1 << 16})
+	_, err := readEvalContext(ctx, `
+		(defn grow [v] (grow [v v v v]))
+		(grow [])`, NewEnv())
+	if !errors.Is(err, ErrAllocLimit) {
+		t.Errorf("Expected: allocation limit\nActual: %v\n", err)
+	}
+}
+
+func TestLimitsEndWithEval(t *testing.T) {
+	env := NewEnv()
+	ctx := WithLimits(context.Background(), Limits{Steps: 5})
+	if _, err := readEvalContext(ctx, "(defn f [n] (if (= n 0) 0 (f (- n 1))))", env); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEvalContext(ctx, "(f 10)", env); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("Expected: step limit\nActual: %v\n", err)
+	}
+	val, err := readEval("(f 10)", env)
+	if err != nil || val != 0 {
+		t.Errorf("\nExpected: 0\nActual: %v %v\n", val, err)
+	}
+}
+
+const countDown = "(defn down [n] (if (= n 0) 0 (+ 1 (down (- n 1)))))"
+
+func TestStackOverflow(t *testing.T) {
+	env := NewEnv()
+	if _, err := readEval(countDown, env); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := readEval("(down 5000)", env)
+	if err != nil || val != 5000 {
+		t.Errorf("\nExpected: 5000\nActual: %v %v\n", val, err)
+	}
+
+	_, err = readEval("(down 1000000)", env)
+	var serr *StackOverflowError
+	if !errors.As(err, &serr) || serr.Depth != MaxDepth {
+		t.Errorf("Expected: stack overflow at %d\nActual: %v\n", MaxDepth, err)
+	}
+
+	// the depth is back to zero after an overflow
+	val, err = readEval("(down 5000)", env)
+	if err != nil || val != 5000 {
+		t.Errorf("\nExpected: 5000\nActual: %v %v\n", val, err)
+	}
+}
+
+func TestDepthLimit(t *testing.T) {
+	env := NewEnv()
+	ctx := WithLimits(context.Background(), Limits{Depth: 100})
+	if _, err := readEvalContext(ctx, countDown, env); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := readEvalContext(ctx, "(down 50)", env)
+	if err != nil || val != 50 {
+		t.Errorf("\nExpected: 50\nActual: %v %v\n", val, err)
+	}
+	_, err = readEvalContext(ctx, "(down 200)", env)
+	var serr *StackOverflowError
+	if !errors.As(err, &serr) || serr.Depth != 100 {
+		t.Errorf("Expected: stack overflow at 100\nActual: %v\n", err)
+	}
+
+	// tail calls don't nest
+	val, err = readEvalContext(ctx, "(defn spin-down [n] (if (= n 0) :done (spin-down (- n 1)))) (spin-down 1000)", env)
+	if err != nil || val != Keyword("done") {
+		t.Errorf("\nExpected: :done\nActual: %v %v\n", val, err)
+	}
+}
+
+func readEvalContext(ctx context.Context, input string, env *Env) (val any, err error) {
+	in := bufio.NewReader(strings.NewReader(input))
+	for {
+		form, rerr := Read(in)
+		if rerr == io.EOF {
+			return val, nil
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+		val, err = EvalContext(ctx, form, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
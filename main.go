@@ -2,24 +2,31 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 )
 
 type Symbol string
 type Keyword string
 type List []any
+type Set map[any]struct{}
 
-func ReadEvalPrint(in *bufio.Reader, env *Env) (string, error) {
-	val, err := Read(in)
+func ReadEvalPrint(ctx context.Context, rd *Reader, in io.RuneScanner, env *Env) (string, error) {
+	val, err := rd.Read(in)
 	if err != nil {
 		return "", err
 	}
 
-	val, err = Eval(val, env)
+	val, err = EvalContext(ctx, val, env)
 	if err != nil {
 		return "", err
 	}
@@ -28,44 +35,173 @@ func ReadEvalPrint(in *bufio.Reader, env *Env) (string, error) {
 	return out, nil
 }
 
-func ReadEvalPrintLoop() {
+func ReadEvalPrintLoop(env *Env) {
 	r := bufio.NewReader(os.Stdin)
-	prompt()
-	env := NewEnv()
+	rd := NewReader(env)
+	interrupts := handleInterrupts()
+	pending := ""
+	prompt("user=> ")
 	for {
-		output, err := ReadEvalPrint(r, env)
-		if err == io.EOF {
+		line, err := r.ReadString('\n')
+		if interrupts.atPrompt() {
+			// the input before an interrupt at the prompt is thrown away
+			pending = ""
+		}
+
+		ctx := interrupts.start()
+		pending = readEvalPrintLines(ctx, rd, pending+line, env, err != nil)
+		interrupts.stop()
+		if err != nil {
 			break
 		}
+
+		if pending != "" {
+			prompt("  #_=> ")
+		} else {
+			prompt("user=> ")
+		}
+	}
+}
+
+// evaluate each complete form in the input, returning the start of a form
+// that continues on the next line (unless this is the last line)
+func readEvalPrintLines(ctx context.Context, rd *Reader, input string, env *Env, last bool) string {
+	in := newScanner(strings.NewReader(input))
+	for {
+		val, err := rd.Read(in)
+		if err == io.EOF {
+			return ""
+		}
+		if errors.Is(err, ErrIncomplete) && !last {
+			return input[in.formOffset:]
+		}
+		// an unfinished form read while evaluating, e.g. by edn/read-string,
+		// is just an error
+		var output string
+		if err == nil {
+			val, err = EvalContext(ctx, val, env)
+			output = Print(val)
+		}
+		if errors.Is(err, context.Canceled) {
+			// skip the rest of the line
+			fmt.Println("interrupted")
+			return ""
+		}
 		if err != nil {
 			fmt.Println(err)
-			prompt()
 			continue
 		}
 
 		fmt.Println(output)
+	}
+}
 
-		peeked, err := r.Peek(1)
-		if err == nil && (peeked[0] == '\r' || peeked[0] == '\n') {
-			prompt()
+// interrupts cancel the running evaluation, or at the prompt, exit if
+// there are two in a row
+type interrupts struct {
+	mu sync.Mutex
+	// cancels the running evaluation, nil at the prompt
+	cancel context.CancelFunc
+	// interrupts at the prompt since the last line of input
+	count int
+}
+
+func handleInterrupts() *interrupts {
+	it := &interrupts{}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		for range c {
+			it.interrupt()
 		}
+	}()
+	return it
+}
+
+func (it *interrupts) interrupt() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.cancel != nil {
+		it.cancel()
+		return
+	}
+
+	it.count++
+	if it.count > 1 {
+		fmt.Println()
+		os.Exit(0)
 	}
+	fmt.Println()
+	fmt.Println("(interrupt again or press Ctrl-D to exit)")
+	prompt("user=> ")
 }
 
-func prompt() {
+// a context for evaluating a line of input, that is cancelled by an interrupt
+func (it *interrupts) start() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	it.mu.Lock()
+	it.cancel = cancel
+	it.mu.Unlock()
+	return ctx
+}
+
+func (it *interrupts) stop() {
+	it.mu.Lock()
+	it.cancel()
+	it.cancel = nil
+	it.mu.Unlock()
+}
+
+// whether there were interrupts while waiting for the last line of input
+func (it *interrupts) atPrompt() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	interrupted := it.count > 0
+	it.count = 0
+	return interrupted
+}
+
+func prompt(p string) {
 	if !isInputRedirected() {
-		fmt.Printf("user=> ")
+		fmt.Print(p)
+	}
+}
+
+func printAudit(r io.Reader) {
+	report, err := Audit(r)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	names := make([]string, 0, len(report.Capabilities))
+	for name := range report.Capabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name+":", joinSymbols(report.Capabilities[name]))
+	}
+	if len(report.Unresolved) > 0 {
+		fmt.Println("unresolved:", joinSymbols(report.Unresolved))
 	}
 }
 
+func joinSymbols(syms []Symbol) string {
+	strs := make([]string, len(syms))
+	for i, sym := range syms {
+		strs[i] = string(sym)
+	}
+	return strings.Join(strs, " ")
+}
+
 func isInputRedirected() bool {
 	fi, _ := os.Stdin.Stat()
 	return (fi.Mode() & os.ModeCharDevice) == 0
 }
 
 func setupCloseHandler() {
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM)
 	go func() {
 		<-c
 		os.Exit(0)
@@ -73,6 +209,36 @@ func setupCloseHandler() {
 }
 
 func main() {
+	flag.BoolVar(&useVM, "vm", false, "evaluate using the bytecode virtual machine")
+	flag.IntVar(&MaxDepth, "max-depth", MaxDepth, "the maximum depth of nested procedure calls")
+	imports := flag.String("import", "", "comma separated go packages to import, e.g. strings,math")
+	sandbox := flag.String("sandbox", "", "only allow these comma separated capabilities, e.g. core,json")
+	audit := flag.Bool("audit", false, "list the capabilities needed by the script on stdin, without running it")
+	flag.Parse()
+
+	if *audit {
+		printAudit(os.Stdin)
+		return
+	}
+
+	env := NewEnv()
+	if *sandbox != "" {
+		var err error
+		env, err = NewSandboxEnv(strings.Split(*sandbox, ",")...)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *imports != "" {
+		for _, pkg := range strings.Split(*imports, ",") {
+			if err := env.Import(pkg); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+	}
+
 	setupCloseHandler()
-	ReadEvalPrintLoop()
+	ReadEvalPrintLoop(env)
 }
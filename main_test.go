@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadEvalPrintLines(t *testing.T) {
+	env := NewEnv()
+	rd := NewReader(env)
+	ctx := context.Background()
+
+	if rest := readEvalPrintLines(ctx, rd, "(+ 1\n", env, false); rest != "(+ 1\n" {
+		t.Errorf("Expected: the unfinished form to continue\nActual: %q", rest)
+	}
+	if rest := readEvalPrintLines(ctx, rd, "(+ 1\n2)\n", env, false); rest != "" {
+		t.Errorf("Expected: no unfinished form\nActual: %q", rest)
+	}
+
+	// reading an unfinished form while evaluating isn't more input, so the
+	// form isn't evaluated again with the next line
+	input := "(def n 0) (do (def n (+ n 1)) (edn/read-string \"(\"))\n"
+	if rest := readEvalPrintLines(ctx, rd, input, env, false); rest != "" {
+		t.Errorf("Expected: no unfinished form\nActual: %q", rest)
+	}
+	if n, err := env.Find("n"); err != nil || n != 1 {
+		t.Errorf("Expected: 1\nActual: %v %v", n, err)
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Associative operations on maps, vectors and records, which return new
+// values rather than changing their args
+
+// (get m key default) looks up a key in a map, record or struct, an index in
+// a vector or an item in a set, returning nil or the default when it's missing
+func get(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to get", len(args))
+		}
+		val, exists, err := getKey(args[0], args[1], env.interop)
+		if err != nil {
+			return nil, err
+		}
+		if !exists && len(args) == 3 {
+			return args[2], nil
+		}
+		return val, nil
+	})
+}
+
+// (get-in m [k1 k2] default) looks up a path of keys in nested values
+func getIn(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to get-in", len(args))
+		}
+		path, err := keyPath("get-in", args[1])
+		if err != nil {
+			return nil, err
+		}
+		val := args[0]
+		for _, key := range path {
+			var exists bool
+			val, exists, err = getKey(val, key, env.interop)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				if len(args) == 3 {
+					return args[2], nil
+				}
+				return nil, nil
+			}
+		}
+		return val, nil
+	})
+}
+
+// like lookup, but where sets are looked up too, and keys that a value
+// can't have and values that can't be indexed are treated as missing keys
+func getKey(coll, key any, interop bool) (any, bool, error) {
+	switch t := coll.(type) {
+	case Set:
+		if !isHashable(key) {
+			return nil, false, nil
+		}
+		_, exists := t[key]
+		if !exists {
+			return nil, false, nil
+		}
+		return key, true, nil
+	case []any:
+		if _, isInt := key.(int); !isInt {
+			return nil, false, nil
+		}
+		return lookup(coll, key, interop)
+	case map[any]any, *record:
+		return lookup(coll, key, interop)
+	}
+
+	if _, isStruct := asStruct(coll); isStruct {
+		if _, isName := fieldName(key); !isName && interop {
+			return nil, false, nil
+		}
+		return lookup(coll, key, interop)
+	}
+	if v := reflect.ValueOf(coll); v.Kind() == reflect.Map {
+		if _, err := toGo(key, v.Type().Key()); err != nil {
+			return nil, false, nil
+		}
+		return lookup(coll, key, interop)
+	}
+	return nil, false, nil
+}
+
+// (assoc m key val & kvs) adds or replaces keys in a map or record, or
+// indexes in a vector, where the index can be one past the end
+func assoc(args []any) (any, error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to assoc", len(args))
+	}
+	ret := args[0]
+	for i := 1; i < len(args); i += 2 {
+		var err error
+		ret, err = assocKey(ret, args[i], args[i+1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func assocKey(coll, key, val any) (any, error) {
+	switch t := coll.(type) {
+	case nil:
+		return buildMap([]any{key, val})
+	case map[any]any:
+		if !isHashable(key) {
+			return nil, fmt.Errorf("map key must be a hashable value: %v", Print(key))
+		}
+		ret := copyMap(t, 1)
+		ret[key] = val
+		return ret, nil
+	case *record:
+		if !isHashable(key) {
+			return nil, fmt.Errorf("map key must be a hashable value: %v", Print(key))
+		}
+		ret := copyMap(t.vals, 1)
+		ret[key] = val
+		return &record{t.rtype, ret}, nil
+	case []any:
+		i, isInt := key.(int)
+		if !isInt {
+			return nil, fmt.Errorf("vector index must be an int: %s", Print(key))
+		}
+		if i < 0 || i > len(t) {
+			return nil, fmt.Errorf("index out of bounds: %d", i)
+		}
+		ret := make([]any, len(t), len(t)+1)
+		copy(ret, t)
+		if i == len(t) {
+			return append(ret, val), nil
+		}
+		ret[i] = val
+		return ret, nil
+	}
+	return nil, fmt.Errorf("can't assoc to %s", Print(coll))
+}
+
+// (assoc-in m [k1 k2] val) sets a value in nested maps or vectors, creating
+// maps for the keys that are missing
+func assocIn(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to assoc-in", len(args))
+		}
+		path, err := keyPath("assoc-in", args[1])
+		if err != nil {
+			return nil, err
+		}
+		return updatePath(args[0], path, func(any) (any, error) {
+			return args[2], nil
+		}, env.interop)
+	})
+}
+
+// (update m key f & args) replaces a value with (f old args...)
+func update(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to update", len(args))
+		}
+		return updatePath(args[0], []any{args[1]}, updater(args[2], args[3:]), env.interop)
+	})
+}
+
+// (update-in m [k1 k2] f & args) replaces a nested value with (f old args...)
+func updateIn(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to update-in", len(args))
+		}
+		path, err := keyPath("update-in", args[1])
+		if err != nil {
+			return nil, err
+		}
+		return updatePath(args[0], path, updater(args[2], args[3:]), env.interop)
+	})
+}
+
+func updater(f any, extra []any) func(old any) (any, error) {
+	return func(old any) (any, error) {
+		return invoke(f, append([]any{old}, extra...))
+	}
+}
+
+// replace the value at the end of a path of keys
+func updatePath(coll any, path []any, f func(old any) (any, error), interop bool) (any, error) {
+	old, _, err := getKey(coll, path[0], interop)
+	if err != nil {
+		return nil, err
+	}
+	var val any
+	if len(path) == 1 {
+		val, err = f(old)
+	} else {
+		val, err = updatePath(old, path[1:], f, interop)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assocKey(coll, path[0], val)
+}
+
+// the keys in a path, which must not be empty
+func keyPath(name string, val any) ([]any, error) {
+	var path []any
+	switch t := val.(type) {
+	case []any:
+		path = t
+	case List:
+		path = t
+	default:
+		return nil, fmt.Errorf("path passed to %s must be a vector: %s", name, Print(val))
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("path passed to %s must not be empty", name)
+	}
+	return path, nil
+}
+
+// (dissoc m & keys) removes keys from a map or record, where removing a
+// field from a record leaves a plain map
+func dissoc(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of args (0) passed to dissoc")
+	}
+	switch t := args[0].(type) {
+	case nil:
+		return nil, nil
+	case map[any]any:
+		ret := copyMap(t, 0)
+		deleteKeys(ret, args[1:])
+		return ret, nil
+	case *record:
+		ret := copyMap(t.vals, 0)
+		deleteKeys(ret, args[1:])
+		for _, field := range t.rtype.fields {
+			if _, exists := ret[field]; !exists {
+				return ret, nil
+			}
+		}
+		return &record{t.rtype, ret}, nil
+	}
+	return nil, fmt.Errorf("can't dissoc from %s", Print(args[0]))
+}
+
+func deleteKeys(m map[any]any, keys []any) {
+	for _, key := range keys {
+		if isHashable(key) {
+			delete(m, key)
+		}
+	}
+}
+
+// (merge & maps) combines maps from left to right, where later keys replace
+// earlier ones and nils are skipped
+func merge(args []any) (any, error) {
+	return mergeWith(nil, args)
+}
+
+// (merge-with f & maps) combines maps, calling (f old new) for keys that are
+// in more than one
+func mergeWithPrim(args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("wrong number of args (0) passed to merge-with")
+	}
+	return mergeWith(args[0], args[1:])
+}
+
+func mergeWith(f any, maps []any) (any, error) {
+	var ret map[any]any
+	var rtype *recordType
+	for _, m := range maps {
+		entries, err := mapEntries(m)
+		if err != nil {
+			return nil, err
+		}
+		if m == nil {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[any]any, len(entries)/2)
+			if r, isRecord := m.(*record); isRecord {
+				rtype = r.rtype
+			}
+		}
+		for i := 0; i < len(entries); i += 2 {
+			key, val := entries[i], entries[i+1]
+			if old, exists := ret[key]; exists && f != nil {
+				if val, err = invoke(f, []any{old, val}); err != nil {
+					return nil, err
+				}
+			}
+			ret[key] = val
+		}
+	}
+
+	if ret == nil {
+		return nil, nil
+	}
+	if rtype != nil {
+		return &record{rtype, ret}, nil
+	}
+	return ret, nil
+}
+
+// (select-keys m [keys]) is a map of only the keys that are in m
+func selectKeys(env *Env) any {
+	return primitive(func(args []any) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to select-keys", len(args))
+		}
+		var keys []any
+		switch t := args[1].(type) {
+		case nil:
+		case []any:
+			keys = t
+		case List:
+			keys = t
+		default:
+			return nil, fmt.Errorf("keys passed to select-keys must be a vector: %s", Print(args[1]))
+		}
+
+		ret := make(map[any]any, len(keys))
+		for _, key := range keys {
+			val, exists, err := getKey(args[0], key, env.interop)
+			if err != nil {
+				return nil, err
+			}
+			if exists && isHashable(key) {
+				ret[key] = val
+			}
+		}
+		return ret, nil
+	})
+}
+
+// (keys m) is a list of the keys of a map or record, or nil if it is empty
+func keys(args []any) (any, error) {
+	return mapColumn("keys", args, 0)
+}
+
+// (vals m) is a list of the values of a map or record, in the same order as
+// its keys
+func vals(args []any) (any, error) {
+	return mapColumn("vals", args, 1)
+}
+
+func mapColumn(name string, args []any, offset int) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to %s", len(args), name)
+	}
+	entries, err := mapEntries(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	ret := make(List, 0, len(entries)/2)
+	for i := offset; i < len(entries); i += 2 {
+		ret = append(ret, entries[i])
+	}
+	return ret, nil
+}
+
+// the alternating keys and values of a map in a stable order: the fields of a
+// record in order, and otherwise sorted by how the keys print
+func mapEntries(val any) ([]any, error) {
+	switch t := val.(type) {
+	case nil:
+		return nil, nil
+	case *record:
+		return t.pairs(), nil
+	case map[any]any:
+		return sortedPairs(t), nil
+	}
+	return nil, fmt.Errorf("expected a map: %s", Print(val))
+}
+
+func sortedPairs(m map[any]any) []any {
+	keys := make([]any, 0, len(m))
+	printed := make(map[any]string, len(m))
+	for k := range m {
+		keys = append(keys, k)
+		printed[k] = Print(k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return printed[keys[i]] < printed[keys[j]] })
+
+	pairs := make([]any, 0, 2*len(m))
+	for _, k := range keys {
+		pairs = append(pairs, k, m[k])
+	}
+	return pairs
+}
+
+func copyMap(m map[any]any, extra int) map[any]any {
+	ret := make(map[any]any, len(m)+extra)
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
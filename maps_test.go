@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	testEval(t, `(get {:a 1} :a)`, 1)
+	testEval(t, `(get {:a 1} :b)`, nil)
+	testEval(t, `(get {:a 1} :b 2)`, 2)
+	testEval(t, `(get nil :a 2)`, 2)
+	testEval(t, `(get [10 20] 1)`, 20)
+	testEval(t, `(get [10 20] 2 :none)`, Keyword("none"))
+	testEval(t, `(get [10 20] :a)`, nil)
+	testEval(t, `(get #{1 2} 2)`, 2)
+	testEval(t, `(get #{1 2} 3 :none)`, Keyword("none"))
+	testEval(t, `(get (testpoint 1 2) :Y)`, 2)
+	testEval(t, `(defrecord Point [x y]) (get (->Point 1 2) :x)`, 1)
+	testEval(t, `(get {:a 1} [1])`, nil)
+	testEval(t, `(get {:a 1} {:b 2} :none)`, Keyword("none"))
+	testEval(t, `(defrecord Point [x y]) (get (->Point 1 2) [1] :none)`, Keyword("none"))
+	testEval(t, `(get 1 :a)`, nil)
+	testEval(t, `(get "abc" 0 :none)`, Keyword("none"))
+	testEval(t, `(get (testpoint 1 2) 1 :none)`, Keyword("none"))
+	testEvalError(t, `(get {:a 1})`)
+
+	testEval(t, `(get-in {:a {:b [1 2]}} [:a :b 1])`, 2)
+	testEval(t, `(get-in {:a {:b 1}} [:a :c])`, nil)
+	testEval(t, `(get-in {:a {:b 1}} [:x :y] 0)`, 0)
+	testEval(t, `(get-in {:a {:b 1}} [:a [1]] 0)`, 0)
+	testEval(t, `(get-in {:a {:b 1}} [{:c 1} :b])`, nil)
+	testEval(t, `(get-in {:a 1} [:a :b])`, nil)
+	testEval(t, `(get-in {:a 1} [:a :b] 0)`, 0)
+	testEvalError(t, `(get-in {:a 1} :a)`)
+	testEvalError(t, `(get-in {:a 1} [])`)
+}
+
+func TestAssoc(t *testing.T) {
+	testEval(t, `(assoc {:a 1} :b 2)`, map[any]any{Keyword("a"): 1, Keyword("b"): 2})
+	testEval(t, `(assoc {:a 1} :a 2 :c 3)`, map[any]any{Keyword("a"): 2, Keyword("c"): 3})
+	testEval(t, `(assoc nil :a 1)`, map[any]any{Keyword("a"): 1})
+	testEval(t, `(assoc [1 2] 0 :x)`, []any{Keyword("x"), 2})
+	testEval(t, `(assoc [1 2] 2 3)`, []any{1, 2, 3})
+	testEval(t, `(def m {:a 1}) (assoc m :a 2) m`, map[any]any{Keyword("a"): 1})
+	testEval(t, `(def v [1 2]) (assoc v 0 5) v`, []any{1, 2})
+	testEval(t, `(defrecord Point [x y]) (= (assoc (->Point 1 2) :x 5) (->Point 5 2))`, true)
+	testEvalError(t, `(assoc [1 2] 3 3)`)
+	testEvalError(t, `(assoc [1 2] :a 3)`)
+	testEvalError(t, `(assoc {} [1] 3)`)
+	testEvalError(t, `(assoc {} :a)`)
+	testEvalError(t, `(assoc 1 :a 2)`)
+
+	testEval(t, `(assoc-in {:a {:b 1}} [:a :b] 2)`, map[any]any{Keyword("a"): map[any]any{Keyword("b"): 2}})
+	testEval(t, `(assoc-in {} [:a :b] 1)`, map[any]any{Keyword("a"): map[any]any{Keyword("b"): 1}})
+	testEval(t, `(assoc-in {:a [1 2]} [:a 1] 3)`, map[any]any{Keyword("a"): []any{1, 3}})
+	testEval(t, `(def m {:a {:b 1}}) (assoc-in m [:a :b] 2) m`, map[any]any{Keyword("a"): map[any]any{Keyword("b"): 1}})
+	testEvalError(t, `(assoc-in {:a 1} [:a :b] 2)`)
+}
+
+func TestUpdate(t *testing.T) {
+	testEval(t, `(update {:a 1} :a + 10)`, map[any]any{Keyword("a"): 11})
+	testEval(t, `(update [1 2] 1 (fn [x] (* x 3)))`, []any{1, 6})
+	testEval(t, `(update {} :a (fn [x] x))`, map[any]any{Keyword("a"): nil})
+	testEval(t, `(update-in {:a {:b 1}} [:a :b] + 1 2)`, map[any]any{Keyword("a"): map[any]any{Keyword("b"): 4}})
+	testEval(t, `(update-in {} [:a :b] (fn [x] :new))`, map[any]any{Keyword("a"): map[any]any{Keyword("b"): Keyword("new")}})
+	testEvalError(t, `(update {:a 1} :a)`)
+	testEvalError(t, `(update {:a :x} :a + 1)`)
+}
+
+func TestDissocMerge(t *testing.T) {
+	testEval(t, `(dissoc {:a 1 :b 2} :a)`, map[any]any{Keyword("b"): 2})
+	testEval(t, `(dissoc {:a 1} :a :b)`, map[any]any{})
+	testEval(t, `(def m {:a 1}) (dissoc m :a) m`, map[any]any{Keyword("a"): 1})
+	testEval(t, `(dissoc nil :a)`, nil)
+	testEval(t, `(defrecord Point [x y]) (= (dissoc (assoc (->Point 1 2) :z 3) :z) (->Point 1 2))`, true)
+	testEval(t, `(defrecord Point [x y]) (dissoc (->Point 1 2) :x)`, map[any]any{Keyword("y"): 2})
+	testEvalError(t, `(dissoc [1 2] 0)`)
+
+	testEval(t, `(merge {:a 1 :b 2} {:b 3} nil {:c 4})`, map[any]any{Keyword("a"): 1, Keyword("b"): 3, Keyword("c"): 4})
+	testEval(t, `(merge)`, nil)
+	testEval(t, `(merge nil nil)`, nil)
+	testEval(t, `(def m {:a 1}) (merge m {:a 2}) m`, map[any]any{Keyword("a"): 1})
+	testEval(t, `(defrecord Point [x y]) (= (merge (->Point 1 2) {:y 5}) (->Point 1 5))`, true)
+	testEvalError(t, `(merge {:a 1} [1 2])`)
+
+	testEval(t, `(merge-with + {:a 1 :b 2} {:a 10} {:a 100 :c 3})`, map[any]any{Keyword("a"): 111, Keyword("b"): 2, Keyword("c"): 3})
+	testEvalError(t, `(merge-with)`)
+}
+
+func TestKeysVals(t *testing.T) {
+	testEval(t, `(select-keys {:a 1 :b 2 :c 3} [:a :c :d])`, map[any]any{Keyword("a"): 1, Keyword("c"): 3})
+	testEval(t, `(select-keys nil [:a])`, map[any]any{})
+	testEval(t, `(select-keys {:a 1} [[1] {:b 2} :a])`, map[any]any{Keyword("a"): 1})
+	testEvalError(t, `(select-keys {:a 1} :a)`)
+
+	testEval(t, `(keys {:b 2 :a 1})`, List{Keyword("a"), Keyword("b")})
+	testEval(t, `(vals {:b 2 :a 1})`, List{1, 2})
+	testEval(t, `(keys {})`, nil)
+	testEval(t, `(vals nil)`, nil)
+	testEval(t, `(defrecord Point [y x]) (keys (->Point 1 2))`, List{Keyword("y"), Keyword("x")})
+	testEvalError(t, `(keys [1 2])`)
+}
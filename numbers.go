@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// The numeric tower: an operation on two numbers is done in the type of the
+// one with the higher rank, so ints become big ints, ratios or floats. int64
+// values from go, like constants that don't fit in an int on every
+// platform, are big ints.
+const (
+	rankInt = iota
+	rankBigInt
+	rankRatio
+	rankBigDecimal
+	rankFloat
+)
+
+func numRank(val any) (int, bool) {
+	switch val.(type) {
+	case int:
+		return rankInt, true
+	case int64, *big.Int:
+		return rankBigInt, true
+	case *big.Rat:
+		return rankRatio, true
+	case *big.Float:
+		return rankBigDecimal, true
+	case float64:
+		return rankFloat, true
+	}
+	return 0, false
+}
+
+// an arithmetic operation on each type of the numeric tower, where the big
+// operations are methods like (*big.Int).Add, and ints that overflow are
+// promoted to big ints
+type arith struct {
+	int        func(int, int) (int, bool)
+	float      func(float64, float64) float64
+	bigInt     func(z, x, y *big.Int) *big.Int
+	ratio      func(z, x, y *big.Rat) *big.Rat
+	bigDecimal func(z, x, y *big.Float) *big.Float
+	// whether the operation is division, which fails for a zero divisor
+	// except with floats, which follow IEEE 754
+	div bool
+}
+
+// apply an arithmetic operation to two numbers
+func (op arith) apply(x, y any) (any, error) {
+	rx, _ := numRank(x)
+	ry, _ := numRank(y)
+	rank := rx
+	if ry > rank {
+		rank = ry
+	}
+	if op.div && rank != rankFloat && isZero(y) {
+		return nil, fmt.Errorf("divide by zero")
+	}
+
+	switch rank {
+	case rankInt:
+		if ret, ok := op.int(x.(int), y.(int)); ok {
+			return ret, nil
+		}
+		return op.bigInt(new(big.Int), toBigInt(x), toBigInt(y)), nil
+	case rankBigInt:
+		return op.bigInt(new(big.Int), toBigInt(x), toBigInt(y)), nil
+	case rankRatio:
+		return normalizeRat(op.ratio(new(big.Rat), toRat(x), toRat(y))), nil
+	case rankBigDecimal:
+		return op.bigDecimal(new(big.Float).SetPrec(bigDecimalPrec), toBigDecimal(x), toBigDecimal(y)), nil
+	default:
+		return op.float(toFloat(x), toFloat(y)), nil
+	}
+}
+
+// compare two numbers, which aren't comparable if either is NaN
+func compareNums(x, y any) (int, bool) {
+	rx, _ := numRank(x)
+	ry, _ := numRank(y)
+	rank := rx
+	if ry > rank {
+		rank = ry
+	}
+
+	switch rank {
+	case rankInt:
+		a, b := x.(int), y.(int)
+		if a < b {
+			return -1, true
+		} else if a > b {
+			return 1, true
+		}
+		return 0, true
+	case rankBigInt:
+		return toBigInt(x).Cmp(toBigInt(y)), true
+	case rankRatio:
+		return toRat(x).Cmp(toRat(y)), true
+	case rankBigDecimal:
+		return toBigDecimal(x).Cmp(toBigDecimal(y)), true
+	default:
+		a, b := toFloat(x), toFloat(y)
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return 0, false
+		}
+		if a < b {
+			return -1, true
+		} else if a > b {
+			return 1, true
+		}
+		return 0, true
+	}
+}
+
+// whether a number is an int or a big int, which = compares by value
+func isInteger(val any) bool {
+	rank, isNum := numRank(val)
+	return isNum && rank <= rankBigInt
+}
+
+func isZero(val any) bool {
+	switch t := val.(type) {
+	case int:
+		return t == 0
+	case int64:
+		return t == 0
+	case *big.Int:
+		return t.Sign() == 0
+	case *big.Rat:
+		return t.Sign() == 0
+	case *big.Float:
+		return t.Sign() == 0
+	case float64:
+		return t == 0
+	}
+	return false
+}
+
+// conversions to a type of a higher rank
+
+func toBigInt(val any) *big.Int {
+	switch t := val.(type) {
+	case int:
+		return big.NewInt(int64(t))
+	case int64:
+		return big.NewInt(t)
+	}
+	return val.(*big.Int)
+}
+
+func toRat(val any) *big.Rat {
+	switch t := val.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(t))
+	case int64:
+		return new(big.Rat).SetInt64(t)
+	case *big.Int:
+		return new(big.Rat).SetInt(t)
+	}
+	return val.(*big.Rat)
+}
+
+func toBigDecimal(val any) *big.Float {
+	f := new(big.Float).SetPrec(bigDecimalPrec)
+	switch t := val.(type) {
+	case int:
+		return f.SetInt64(int64(t))
+	case int64:
+		return f.SetInt64(t)
+	case *big.Int:
+		return f.SetInt(t)
+	case *big.Rat:
+		return f.SetRat(t)
+	}
+	return val.(*big.Float)
+}
+
+func toFloat(val any) float64 {
+	switch t := val.(type) {
+	case int:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(t).Float64()
+		return f
+	case *big.Rat:
+		f, _ := t.Float64()
+		return f
+	case *big.Float:
+		f, _ := t.Float64()
+		return f
+	}
+	return val.(float64)
+}
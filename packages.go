@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+//go:generate go run ./cmd/bindgen -o bindings_gen.go fmt math path/filepath strconv strings time
+
+// go packages whose functions and constants can be imported into an
+// environment, keyed by import path
+var packages = make(map[string]map[Symbol]any)
+
+// the capability that a sandbox needs to allow each go package, or "" for
+// packages without side effects. Packages that aren't listed can't be
+// allowed in a sandbox.
+var packageCapabilities = map[string]string{
+	"fmt":           "print", // Print and Scan use stdout and stdin
+	"math":          "",
+	"path/filepath": "fs", // Glob, Walk, Abs, ...
+	"strconv":       "",
+	"strings":       "",
+	"time":          "clock", // Now, Sleep, AfterFunc, ...
+}
+
+// Import the functions and constants of a go package into the environment
+// under symbols qualified by the package name (strings.ToUpper, math.Pi). A
+// sandbox needs the capability for any side effects that the package has.
+func (e *Env) Import(pkg string) error {
+	bindings, exists := packages[pkg]
+	if !exists {
+		return fmt.Errorf("no bindings for package: %s", pkg)
+	}
+	if allowed := e.root().allowed; allowed != nil {
+		if err := checkPackage(pkg, allowed); err != nil {
+			return err
+		}
+	}
+	for sym, val := range bindings {
+		e.Define(sym, val)
+	}
+	return nil
+}
+
+// the import paths of every package with bindings
+func Packages() []string {
+	ret := make([]string, 0, len(packages))
+	for pkg := range packages {
+		ret = append(ret, pkg)
+	}
+	sort.Strings(ret)
+	return ret
+}
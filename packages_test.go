@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func testImportEval(t *testing.T, input string, output any, pkgs ...string) {
+	t.Helper()
+	env := ChildEnv(NewEnv())
+	for _, pkg := range pkgs {
+		if err := env.Import(pkg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	actual, err := readEval(input, env)
+	if err != nil {
+		t.Errorf("\nany: %s\nExpected: %v\nActual: Error - %s\n", input, Print(output), err)
+		return
+	}
+	if !Equals(actual, output) {
+		t.Errorf("\nany: %s\nExpected: %v\nActual: %v\n", input, Print(output), Print(actual))
+	}
+}
+
+func TestImport(t *testing.T) {
+	testImportEval(t, `(strings.ToUpper "abc")`, "ABC", "strings")
+	testImportEval(t, `(strings.Repeat "ab" 3)`, "ababab", "strings")
+	testImportEval(t, "(math.Sqrt 16.0)", 4.0, "math")
+	testImportEval(t, "math.Pi", 3.141592653589793, "math")
+	testImportEval(t, "math.MaxInt8", 127, "math")
+	testImportEval(t, "(+ math.MaxUint32 1)", big.NewInt(math.MaxUint32+1), "math")
+	testImportEval(t, "(- math.MaxInt64 1)", big.NewInt(math.MaxInt64-1), "math")
+	testImportEval(t, "(= math.MinInt64 -9223372036854775808)", true, "math")
+	testImportEval(t, `(strconv.Quote "a")`, `"a"`, "strconv")
+	testImportEval(t, `(filepath.Join "a" "b")`, "a/b", "path/filepath")
+	testImportEval(t, `(.String (time.ParseDuration "90s"))`, "1m30s", "time")
+	testImportEval(t, `(strings.ToLower (strconv.Itoa 10))`, "10", "strings", "strconv")
+}
+
+func TestImportIsPerEnvironment(t *testing.T) {
+	env := ChildEnv(NewEnv())
+	if err := env.Import("strings"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEval(`(strings.ToUpper "abc")`, NewEnv()); err == nil {
+		t.Error("expected strings.ToUpper to only be bound where it was imported")
+	}
+}
+
+func TestImportUnknownPackage(t *testing.T) {
+	if err := NewEnv().Import("net/http"); err == nil {
+		t.Error("expected an error importing a package without bindings")
+	}
+}
+
+func TestPackages(t *testing.T) {
+	pkgs := Packages()
+	for _, pkg := range []string{"math", "path/filepath", "strconv", "strings", "time"} {
+		if _, exists := packages[pkg]; !exists {
+			t.Errorf("missing bindings for %s in %v", pkg, pkgs)
+		}
+	}
+}
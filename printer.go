@@ -2,25 +2,192 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
+// Print renders a value using syntax that Read can parse back into an equal
+// value.  Values with no literal syntax (functions, go objects) are printed
+// in an opaque #<...> form.
 func Print(val any) string {
 	switch t := val.(type) {
-	case string, rune:
-		return fmt.Sprintf("%q", t)
 	case nil:
 		return "nil"
+	case bool:
+		return strconv.FormatBool(t)
+	case int:
+		return strconv.Itoa(t)
+	case float64:
+		return printFloat(t)
+	case *big.Int:
+		return t.String() + "N"
+	case *big.Rat:
+		return t.RatString()
+	case *big.Float:
+		return t.Text('g', -1) + "M"
+	case string:
+		return printString(t)
+	case rune:
+		return printChar(t)
+	case Symbol:
+		return string(t)
+	case Keyword:
+		return fmt.Sprintf(":%s", t)
 	case List:
 		return fmt.Sprintf("(%s)", printSlice(t))
 	case []any:
 		return fmt.Sprintf("[%s]", printSlice(t))
 	case map[any]any:
 		return fmt.Sprintf("{%s}", printMap(t))
-	case Keyword:
-		return fmt.Sprintf(":%s", t)
+	case mapForm:
+		return fmt.Sprintf("{%s}", printPairs(t))
+	case Set:
+		return fmt.Sprintf("#{%s}", printSlice(setItems(t)))
+	case setForm:
+		return fmt.Sprintf("#{%s}", printSlice(t))
+	case time.Time:
+		return fmt.Sprintf("#inst %s", printString(t.Format(time.RFC3339Nano)))
+	case UUID:
+		return fmt.Sprintf("#uuid %s", printString(t.String()))
+	case *procedure:
+		return printFn(t.name)
+	case *closure:
+		return printFn(t.proto.name)
+	case *Var:
+		return fmt.Sprintf("#'%s", t.name)
+	case *record:
+		return fmt.Sprintf("#%s{%s}", recordTag(t.rtype), printPairs(t.pairs()))
+	case *recordType:
+		return fmt.Sprintf("#<type %s>", printType(t))
+	case *multiFn:
+		return fmt.Sprintf("#<multifn %s>", t.name)
+	case *protocol:
+		return fmt.Sprintf("#<protocol %s>", t.name)
+	case *protocolFn:
+		return printFn(string(t.name))
+	case reflect.Type:
+		return fmt.Sprintf("#<type %s>", printType(t))
+	case specialform:
+		return fmt.Sprintf("#<special-form %s>", funcName(t))
+	case primitive:
+		return printFn(funcName(t))
+	case tailPrimitive:
+		return printFn(funcName(t))
 	default:
+		return printGoValue(val)
+	}
+}
+
+func printFloat(val float64) string {
+	switch {
+	case math.IsInf(val, 1):
+		return "##Inf"
+	case math.IsInf(val, -1):
+		return "##-Inf"
+	case math.IsNaN(val):
+		return "##NaN"
+	}
+	s := strconv.FormatFloat(val, 'g', -1, 64)
+	// make sure that the value doesn't read back as an int
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func printString(val string) string {
+	var sb strings.Builder
+	sb.WriteRune('"')
+	for _, ch := range val {
+		switch ch {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			sb.WriteRune(ch)
+		}
+	}
+	sb.WriteRune('"')
+	return sb.String()
+}
+
+var charNames = map[rune]string{
+	'\n': "newline",
+	' ':  "space",
+	'\t': "tab",
+	'\b': "backspace",
+	'\f': "formfeed",
+	'\r': "return",
+}
+
+func printChar(val rune) string {
+	name, isNamed := charNames[val]
+	if isNamed {
+		return `\` + name
+	}
+	if !unicode.IsGraphic(val) && val <= 0xffff {
+		return fmt.Sprintf(`\u%04x`, val)
+	}
+	return `\` + string(val)
+}
+
+func printFn(name string) string {
+	if name == "" {
+		return "#<fn>"
+	}
+	return fmt.Sprintf("#<fn %s>", name)
+}
+
+// the name of a go function without the package prefix for this package,
+// where closures are named after the function that made them, e.g. derive
+// rather than derive.func1
+func funcName(fun any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fun).Pointer())
+	if f == nil {
+		return ""
+	}
+	name := strings.TrimPrefix(f.Name(), localPrefix)
+	if i := strings.Index(name, ".func"); i > 0 && i+5 < len(name) && isDigit(name[i+5]) {
+		name = name[:i]
+	}
+	return name
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// "main." when built as a binary, the module path when under test
+var localPrefix = strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(printFn).Pointer()).Name(), "printFn")
+
+func printGoValue(val any) string {
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32:
 		return fmt.Sprintf("%v", val)
+	case reflect.Func:
+		return printFn(funcName(val))
+	default:
+		return fmt.Sprintf("#<go-object %T>", val)
 	}
 }
 
@@ -37,17 +204,16 @@ func printSlice(val []any) string {
 }
 
 func printMap(val map[any]any) string {
-	if len(val) < 1 {
-		return ""
-	}
+	return printPairs(mapPairs(val))
+}
+
+func printPairs(val []any) string {
 	var ret strings.Builder
-	i := 0
-	for k, v := range val {
+	for i := 0; i < len(val); i += 2 {
 		if i != 0 {
 			fmt.Fprintf(&ret, ", ")
 		}
-		fmt.Fprintf(&ret, "%s %s", Print(k), Print(v))
-		i++
+		fmt.Fprintf(&ret, "%s %s", Print(val[i]), Print(val[i+1]))
 	}
 	return ret.String()
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrint(t *testing.T) {
+	testPrint(t, nil, "nil")
+	testPrint(t, true, "true")
+	testPrint(t, 42, "42")
+	testPrint(t, -3.5, "-3.5")
+	testPrint(t, 2.0, "2.0")
+	testPrint(t, math.Inf(-1), "##-Inf")
+	testPrint(t, math.NaN(), "##NaN")
+	testPrint(t, big.NewInt(-7), "-7N")
+	testPrint(t, big.NewRat(2, 6), "1/3")
+	testPrint(t, big.NewFloat(1.25), "1.25M")
+	testPrint(t, "a\"b\n", `"a\"b\n"`)
+	testPrint(t, 'a', `\a`)
+	testPrint(t, '\n', `\newline`)
+	testPrint(t, ' ', `\space`)
+	testPrint(t, '\u0007', `\u0007`)
+	testPrint(t, Symbol("abc"), "abc")
+	testPrint(t, Keyword("kw"), ":kw")
+	testPrint(t, List{1, List{}, []any{2, 'c'}}, `(1 () [2 \c])`)
+	testPrint(t, map[any]any{Keyword("a"): 1}, "{:a 1}")
+	testPrint(t, Set{Keyword("a"): {}}, "#{:a}")
+	testPrint(t, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), `#inst "2020-01-02T03:04:05.000000006Z"`)
+	testPrint(t, UUID{0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6},
+		`#uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bf6"`)
+}
+
+func TestPrintOpaque(t *testing.T) {
+	testPrint(t, primitive(add), "#<fn add>")
+	testPrint(t, specialform(ifprim), "#<special-form ifprim>")
+	testPrint(t, fmt.Println, "#<fn fmt.Println>")
+	testPrint(t, &strings.Builder{}, "#<go-object *strings.Builder>")
+
+	val, err := readEval("(defn add1 [x] (+ 1 x)) add1", NewEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPrint(t, val, "#<fn add1>")
+
+	val, err = readEval("(fn [x] x)", NewEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPrint(t, val, "#<fn>")
+
+	// builtins that are bound to an environment are closures
+	for sym, expected := range map[string]string{
+		"derive": "#<fn derive>",
+		"isa?":   "#<fn isa>",
+		"get-in": "#<fn getIn>",
+	} {
+		val, err = readEval(sym, NewEnv())
+		if err != nil {
+			t.Fatal(err)
+		}
+		testPrint(t, val, expected)
+	}
+}
+
+func TestPrintRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		testRoundTrip(t, genValue(rnd, 3))
+	}
+}
+
+func TestPrintRoundTripNumbers(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-98765432109876543210", 10)
+	for _, val := range []any{huge, big.NewInt(3), big.NewRat(-22, 7), big.NewFloat(0.5), math.Inf(1), '\u0000'} {
+		testRoundTrip(t, val)
+	}
+}
+
+func FuzzPrintRoundTrip(f *testing.F) {
+	f.Add(`(+ 1 2)`)
+	f.Add(`[1 2.5 "abc\n" \a \space :kw sym]`)
+	f.Add(`{:a {"b" [nil true false]}}`)
+	f.Add(`(1e21 -0.001 100000.0)`)
+	f.Add(`(0x1F 2r101 1/3 7N 1.5M ##Inf \u00e9 "\101")`)
+	f.Add(`#{1 #inst "2020-01-01" #uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bf6"}`)
+	f.Fuzz(func(t *testing.T, input string) {
+		val, err := Read(bufio.NewReader(strings.NewReader(input)))
+		if err != nil || strings.Contains(Print(val), "##NaN") {
+			// NaN is never equal to itself
+			return
+		}
+		testRoundTrip(t, val)
+	})
+}
+
+func testPrint(t *testing.T, val any, expected string) {
+	t.Helper()
+	actual := Print(val)
+	if actual != expected {
+		t.Errorf("\nExpected: %s\nActual: %s\n", expected, actual)
+	}
+}
+
+func testRoundTrip(t *testing.T, val any) {
+	t.Helper()
+	printed := Print(val)
+	actual, err := Read(bufio.NewReader(strings.NewReader(printed)))
+	if err != nil {
+		t.Errorf("\nPrinted: %s\nError: %s\n", printed, err)
+		return
+	}
+	if !Equals(actual, val) {
+		t.Errorf("\nPrinted: %s\nReprinted: %s\n", printed, Print(actual))
+	}
+}
+
+const genRunes = "abcxyz019 -_+*!?<>=/.\"\\\n\t\r\b\f,;()[]{}#:'λ世"
+const genSymbolRunes = "abcxyz019-_+*!?<>=/."
+
+// generate a random readable value, nesting collections up to depth
+func genValue(rnd *rand.Rand, depth int) any {
+	kinds := 11
+	if depth > 0 {
+		kinds = 15
+	}
+	switch rnd.Intn(kinds) {
+	case 0:
+		return nil
+	case 1:
+		return rnd.Intn(2) == 0
+	case 2:
+		return rnd.Intn(2000) - 1000
+	case 3:
+		return (rnd.Float64() - 0.5) * float64(rnd.Intn(1e9))
+	case 4:
+		return genString(rnd, genRunes)
+	case 5:
+		return []rune(genRunes)[rnd.Intn(len([]rune(genRunes)))]
+	case 6:
+		return Symbol(genSymbol(rnd))
+	case 7:
+		return Keyword(genSymbol(rnd))
+	case 8:
+		return rnd.NormFloat64()
+	case 9:
+		return time.Unix(rnd.Int63n(1e10), rnd.Int63n(1e9)).UTC()
+	case 10:
+		var u UUID
+		rnd.Read(u[:])
+		return u
+	case 11:
+		return List(genSlice(rnd, depth))
+	case 12:
+		return genSlice(rnd, depth)
+	case 13:
+		s := make(Set)
+		for i := rnd.Intn(4); i > 0; i-- {
+			s[genValue(rnd, 0)] = struct{}{}
+		}
+		return s
+	default:
+		m := make(map[any]any)
+		for i := rnd.Intn(4); i > 0; i-- {
+			m[genValue(rnd, 0)] = genValue(rnd, depth-1)
+		}
+		return m
+	}
+}
+
+func genSlice(rnd *rand.Rand, depth int) []any {
+	ret := make([]any, rnd.Intn(5))
+	for i := range ret {
+		ret[i] = genValue(rnd, depth-1)
+	}
+	return ret
+}
+
+func genString(rnd *rand.Rand, from string) string {
+	runes := []rune(from)
+	var sb strings.Builder
+	for i := rnd.Intn(8); i > 0; i-- {
+		sb.WriteRune(runes[rnd.Intn(len(runes))])
+	}
+	return sb.String()
+}
+
+func genSymbol(rnd *rand.Rand) string {
+	// symbols can't start with a digit and can't collide with nil/true/false
+	return "s" + genString(rnd, genSymbolRunes)
+}
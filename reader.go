@@ -4,32 +4,175 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-var macros map[rune]func(r *bufio.Reader) (any, error)
+// A ReaderMacro runs after its character has been read
+// (macros that don't produce a value, like comments, return r)
+type ReaderMacro func(rd *Reader, r io.RuneScanner) (any, error)
+
+// A TagReader converts the form that follows a tag like #inst into a value
+type TagReader func(val any) (any, error)
+
+// A Reader holds the syntax understood by an interpreter, which can be
+// extended with tagged literals and dispatch macros
+type Reader struct {
+	macros map[rune]ReaderMacro
+	// macros that follow the # dispatch character
+	dispatch map[rune]ReaderMacro
+	tags     map[Symbol]TagReader
+	// only accept edn, the data subset of the syntax
+	edn bool
+	// the environment whose *data-readers* handle unregistered tags
+	env *Env
+}
+
+var defaultReader, ednReader *Reader
 
 func init() {
-	macros = map[rune]func(r *bufio.Reader) (any, error){
-		'"':  stringReader,
-		';':  commentReader,
-		'(':  listReader,
-		')':  unmatchedDelimiterReader,
-		'[':  vectorReader,
-		']':  unmatchedDelimiterReader,
-		'{':  mapReader,
-		'}':  unmatchedDelimiterReader,
-		'\\': characterReader,
+	ednReader = &Reader{
+		macros: map[rune]ReaderMacro{
+			'"':  stringReader,
+			';':  commentReader,
+			'(':  listReader,
+			')':  unmatchedDelimiterReader,
+			'[':  vectorReader,
+			']':  unmatchedDelimiterReader,
+			'{':  mapReader,
+			'}':  unmatchedDelimiterReader,
+			'\\': characterReader,
+			'#':  dispatchReader,
+		},
+		dispatch: map[rune]ReaderMacro{
+			'{': setReader,
+			'_': discardReader,
+			'#': symbolicValueReader,
+		},
+		tags: map[Symbol]TagReader{
+			"inst": instReader,
+			"uuid": uuidReader,
+		},
+		edn: true,
+	}
+	defaultReader = ednReader.clone()
+	defaultReader.edn = false
+
+	// shorthands for forms, which aren't part of edn
+	defaultReader.macros['\''] = wrapReader("quote")
+	defaultReader.macros['@'] = wrapReader("deref")
+	defaultReader.macros['^'] = metaReader
+	defaultReader.dispatch['\''] = wrapReader("var")
+	defaultReader.dispatch['('] = fnLiteralReader
+}
+
+func (rd *Reader) clone() *Reader {
+	ret := &Reader{
+		macros:   make(map[rune]ReaderMacro),
+		dispatch: make(map[rune]ReaderMacro),
+		tags:     make(map[Symbol]TagReader),
+		edn:      rd.edn,
+		env:      rd.env,
+	}
+	for k, v := range rd.macros {
+		ret.macros[k] = v
 	}
+	for k, v := range rd.dispatch {
+		ret.dispatch[k] = v
+	}
+	for k, v := range rd.tags {
+		ret.tags[k] = v
+	}
+	return ret
+}
+
+// Create a reader for an interpreter, where tags without a registered
+// handler are looked up in *data-readers* in env
+func NewReader(env *Env) *Reader {
+	rd := defaultReader.clone()
+	rd.env = env
+	return rd
+}
+
+// Register a handler for a tagged literal, e.g. #money "12.50 USD"
+func (rd *Reader) RegisterTag(tag string, fn TagReader) {
+	rd.tags[Symbol(tag)] = fn
+}
+
+// Register a macro for a character that follows #
+// (letters are reserved for tagged literals)
+func (rd *Reader) RegisterDispatch(ch rune, fn ReaderMacro) error {
+	if unicode.IsLetter(ch) || isWhitespace(ch) {
+		return fmt.Errorf("invalid dispatch character: %s", string(ch))
+	}
+	rd.dispatch[ch] = fn
+	return nil
 }
 
 func isWhitespace(ch rune) bool {
 	return unicode.IsSpace(ch) || ch == ','
 }
 
-func Read(r *bufio.Reader) (any, error) {
+// Read a single form using the default syntax
+func Read(r io.RuneScanner) (any, error) {
+	return defaultReader.Read(r)
+}
+
+// ErrIncomplete is returned (wrapped in a SyntaxError) when the input ends
+// in the middle of a form, which more input could complete
+var ErrIncomplete = errors.New("incomplete form")
+
+// Read a single form. After a syntax error, the rest of the top level form
+// is skipped so that the next read starts at the following form.
+func (rd *Reader) Read(r io.RuneScanner) (any, error) {
+	s, isScanner := r.(*scanner)
+	if !isScanner {
+		s = newScanner(r)
+	}
+
+	s.nested++
+	val, err := rd.read(s)
+	s.nested--
+	if err != nil && s.nested == 0 {
+		err = s.recover(err)
+	}
+	return val, err
+}
+
+// A Form is a value read from the input, with the position it started at
+type Form struct {
+	Value        any
+	Line, Column int
+}
+
+// Read every form using the default syntax
+func ReadAll(r io.Reader) ([]Form, error) {
+	return defaultReader.ReadAll(r)
+}
+
+// Read every form in the input, stopping at the first error
+func (rd *Reader) ReadAll(r io.Reader) ([]Form, error) {
+	s := newScanner(bufio.NewReader(r))
+	var forms []Form
+	for {
+		val, err := rd.Read(s)
+		if err == io.EOF {
+			return forms, nil
+		}
+		if err != nil {
+			return forms, err
+		}
+		forms = append(forms, Form{val, s.formLine, s.formCol})
+	}
+}
+
+func (rd *Reader) read(r *scanner) (any, error) {
 	for {
 		ch, _, err := r.ReadRune()
 
@@ -40,14 +183,19 @@ func Read(r *bufio.Reader) (any, error) {
 		if err != nil {
 			return nil, err
 		}
+		line, col := lastPosition(r)
+		if r.nested == 1 {
+			r.formLine, r.formCol, r.formOffset = line, col, r.lastOffset
+		}
 
 		if unicode.IsDigit(ch) {
-			return readNumber(r, ch)
+			ret, err := rd.readNumber(r, ch)
+			return ret, syntaxError(line, col, err)
 		}
 
-		macroFn, isMacro := macros[ch]
+		macroFn, isMacro := rd.macros[ch]
 		if isMacro {
-			ret, err := macroFn(r)
+			ret, err := macroFn(rd, r)
 			if ret == r { //no op macros return the reader
 				continue
 			}
@@ -58,27 +206,29 @@ func Read(r *bufio.Reader) (any, error) {
 			ch2, _, _ := r.ReadRune()
 			r.UnreadRune()
 			if unicode.IsDigit(ch2) {
-				return readNumber(r, ch)
+				ret, err := rd.readNumber(r, ch)
+				return ret, syntaxError(line, col, err)
 			}
 		}
 
-		token, err := readToken(r, ch)
+		token, err := rd.readToken(r, ch)
 		if err != nil {
 			return nil, err
 		}
 
-		return interpretToken(token)
+		ret, err := rd.interpretToken(token)
+		return ret, syntaxError(line, col, err)
 	}
 }
 
-func readToken(r *bufio.Reader, initch rune) (string, error) {
+func (rd *Reader) readToken(r io.RuneScanner, initch rune) (string, error) {
 	var sb strings.Builder
 	sb.WriteRune(initch)
 
 	for {
 		ch, _, err := r.ReadRune()
 
-		if err != nil || isWhitespace(ch) || isMacro(ch) {
+		if err != nil || isWhitespace(ch) || rd.isTerminating(ch) {
 			r.UnreadRune()
 			return sb.String(), nil
 		}
@@ -87,13 +237,13 @@ func readToken(r *bufio.Reader, initch rune) (string, error) {
 	}
 }
 
-func readNumber(r *bufio.Reader, initch rune) (any, error) {
+func (rd *Reader) readNumber(r io.RuneScanner, initch rune) (any, error) {
 	var sb strings.Builder
 	sb.WriteRune(initch)
 
 	for {
 		ch, _, err := r.ReadRune()
-		if err != nil || isWhitespace(ch) || isMacro(ch) {
+		if err != nil || isWhitespace(ch) || rd.isTerminating(ch) {
 			r.UnreadRune()
 			break
 		}
@@ -103,7 +253,7 @@ func readNumber(r *bufio.Reader, initch rune) (any, error) {
 	return matchNumber(sb.String())
 }
 
-func interpretToken(s string) (any, error) {
+func (rd *Reader) interpretToken(s string) (any, error) {
 	if s == "nil" {
 		return nil, nil
 	}
@@ -114,87 +264,262 @@ func interpretToken(s string) (any, error) {
 		return false, nil
 	}
 	if s[0] == ':' {
+		if rd.edn && (len(s) == 1 || s[1] == ':') {
+			return nil, fmt.Errorf("invalid keyword: %s", s)
+		}
 		return Keyword(s[1:]), nil
 	} else {
 		return Symbol(s), nil
 	}
 }
 
+// the largest precision of a big decimal, in bits
+const bigDecimalPrec = 256
+
 func matchNumber(s string) (any, error) {
-	i, erri := strconv.Atoi(s)
-	if erri == nil {
-		return i, nil
+	ret, err := parseNumber(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number: %s", s)
+	}
+	return ret, nil
+}
+
+// parse integers (with hex 0xFF and radix 2r1010 forms), floats, ratios,
+// and big numbers with an N or M suffix
+func parseNumber(s string) (any, error) {
+	sign, body := "", s
+	if s[0] == '+' || s[0] == '-' {
+		sign, body = s[:1], s[1:]
+	}
+	if sign == "+" {
+		sign = ""
+	}
+
+	switch {
+	case strings.HasSuffix(body, "N"):
+		return parseInteger(sign, body[:len(body)-1])
+	case strings.HasSuffix(body, "M"):
+		f, _, err := big.ParseFloat(sign+body[:len(body)-1], 10, bigDecimalPrec, big.ToNearestEven)
+		return f, err
+	case strings.Contains(body, "/"):
+		return parseRatio(sign, body)
+	case isDecimalDigits(body) || strings.HasPrefix(body, "0x") || strings.HasPrefix(body, "0X") ||
+		strings.Contains(body, "r") || strings.Contains(body, "R"):
+		i, err := parseInteger(sign, body)
+		if err != nil {
+			return nil, err
+		}
+		return normalizeInt(i), nil
+	}
+
+	if strings.ContainsAny(body, "_xXpP") {
+		// ParseFloat accepts hex floats and underscores, which clojure doesn't
+		return nil, fmt.Errorf("invalid number: %s", s)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parse an integer in decimal, hex or radix form into a big int
+func parseInteger(sign, body string) (*big.Int, error) {
+	base := 10
+	digits := body
+	if strings.HasPrefix(body, "0x") || strings.HasPrefix(body, "0X") {
+		base, digits = 16, body[2:]
+	} else if idx := strings.IndexAny(body, "rR"); idx > 0 {
+		radix, err := strconv.Atoi(body[:idx])
+		if err != nil || radix < 2 || radix > 36 {
+			return nil, fmt.Errorf("invalid radix: %s", body[:idx])
+		}
+		base, digits = radix, body[idx+1:]
+	}
+
+	if digits == "" || strings.ContainsAny(digits, "_+-") {
+		return nil, fmt.Errorf("invalid integer: %s", body)
+	}
+	i, ok := new(big.Int).SetString(sign+digits, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer: %s", body)
+	}
+	return i, nil
+}
+
+func parseRatio(sign, body string) (any, error) {
+	parts := strings.SplitN(body, "/", 2)
+	if !isDecimalDigits(parts[0]) || !isDecimalDigits(parts[1]) {
+		return nil, fmt.Errorf("invalid ratio: %s", body)
+	}
+	num, _ := new(big.Int).SetString(sign+parts[0], 10)
+	den, _ := new(big.Int).SetString(parts[1], 10)
+	if den.Sign() == 0 {
+		return nil, fmt.Errorf("divide by zero")
+	}
+	return normalizeRat(new(big.Rat).SetFrac(num, den)), nil
+}
+
+func isDecimalDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// use an int when a big int is small enough
+func normalizeInt(i *big.Int) any {
+	if i.IsInt64() && i.Int64() >= math.MinInt && i.Int64() <= math.MaxInt {
+		return int(i.Int64())
 	}
-	f, errf := strconv.ParseFloat(s, 64)
-	if errf == nil {
-		return f, nil
+	return i
+}
+
+// ratios with a denominator of 1 are integers
+func normalizeRat(r *big.Rat) any {
+	if r.IsInt() {
+		return normalizeInt(new(big.Int).Set(r.Num()))
 	}
-	return nil, fmt.Errorf("invalid number: %s", s)
+	return r
 }
 
-func isMacro(ch rune) bool {
-	_, ismacro := macros[ch]
-	return ismacro
+// whether a character ends a token, where # and ' can appear inside of symbols
+func (rd *Reader) isTerminating(ch rune) bool {
+	_, ismacro := rd.macros[ch]
+	return ismacro && ch != '#' && ch != '\''
 }
 
-func stringReader(r *bufio.Reader) (any, error) {
+func stringReader(rd *Reader, r io.RuneScanner) (any, error) {
 	var sb strings.Builder
+	setInString(r, true)
 
 	for ch, _, err := r.ReadRune(); ch != '"'; ch, _, err = r.ReadRune() {
+		if err == io.EOF {
+			return nil, unexpectedEOF(err)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error while reading string: %v", err)
 		}
 		if ch == '\\' {
-			ch, _, err = r.ReadRune()
+			line, col := lastPosition(r)
+			ch, err = readEscape(r)
 			if err != nil {
-				return nil, err
-			}
-			switch ch {
-			case 't':
-				ch = '\t'
-			case 'r':
-				ch = '\r'
-			case 'n':
-				ch = '\n'
-			case 'b':
-				ch = '\b'
-			case 'f':
-				ch = '\f'
-			case '\\':
-			case '"':
-			default:
-				return nil, fmt.Errorf("unsupported escape character: \\%s", string(ch))
+				return nil, syntaxError(line, col, err)
 			}
 		}
 		sb.WriteRune(ch)
 	}
 
+	setInString(r, false)
 	return sb.String(), nil
 }
 
-func commentReader(r *bufio.Reader) (any, error) {
+// read the character after a \ in a string
+func readEscape(r io.RuneScanner) (rune, error) {
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	switch ch {
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case 'n':
+		return '\n', nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case '\\', '"':
+		return ch, nil
+	case 'u':
+		var sb strings.Builder
+		for i := 0; i < 4; i++ {
+			ch, _, err := r.ReadRune()
+			if err != nil {
+				return 0, unexpectedEOF(err)
+			}
+			sb.WriteRune(ch)
+		}
+		return parseUnicode(sb.String())
+	}
+
+	if isOctal(ch) {
+		// up to three octal digits, e.g. \101
+		digits := string(ch)
+		for len(digits) < 3 {
+			next, _, err := r.ReadRune()
+			if err != nil {
+				break
+			}
+			if !isOctal(next) {
+				r.UnreadRune()
+				break
+			}
+			digits += string(next)
+		}
+		return parseOctal(digits)
+	}
+	return 0, fmt.Errorf("unsupported escape character: \\%s", string(ch))
+}
+
+func isOctal(ch rune) bool {
+	return ch >= '0' && ch <= '7'
+}
+
+// the character for the 4 hex digits of a \uXXXX escape
+func parseUnicode(digits string) (rune, error) {
+	n, err := strconv.ParseUint(digits, 16, 16)
+	if err != nil || len(digits) != 4 || utf8.RuneLen(rune(n)) < 0 {
+		return 0, fmt.Errorf("invalid unicode escape: \\u%s", digits)
+	}
+	return rune(n), nil
+}
+
+// the character for up to 3 octal digits, which can't be more than 0377
+func parseOctal(digits string) (rune, error) {
+	n, err := strconv.ParseUint(digits, 8, 16)
+	if err != nil || len(digits) > 3 || n > 0377 {
+		return 0, fmt.Errorf("invalid octal escape: \\%s", digits)
+	}
+	return rune(n), nil
+}
+
+func commentReader(rd *Reader, r io.RuneScanner) (any, error) {
 	ch, _, err := r.ReadRune()
-	for err != nil && ch != '\n' && ch != '\r' {
+	for err == nil && ch != '\n' && ch != '\r' {
 		ch, _, err = r.ReadRune()
 	}
 	return r, nil
 }
 
-func characterReader(r *bufio.Reader) (any, error) {
+func characterReader(rd *Reader, r io.RuneScanner) (any, error) {
+	line, col := lastPosition(r)
 	ch, _, err := r.ReadRune()
 	if err != nil {
-		return nil, err
+		return nil, unexpectedEOF(err)
 	}
 
-	token, err := readToken(r, ch)
+	token, err := rd.readToken(r, ch)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(token) == 1 {
+	if utf8.RuneCountInString(token) == 1 {
 		return []rune(token)[0], nil
 	}
 
+	if token[0] == 'u' && len(token) > 1 {
+		ch, err := parseUnicode(token[1:])
+		return ch, syntaxError(line, col, err)
+	}
+	if token[0] == 'o' && len(token) > 1 {
+		ch, err := parseOctal(token[1:])
+		return ch, syntaxError(line, col, err)
+	}
+
 	switch token {
 	case "newline":
 		return '\n', nil
@@ -209,48 +534,66 @@ func characterReader(r *bufio.Reader) (any, error) {
 	case "return":
 		return '\r', nil
 	default:
-		return nil, fmt.Errorf("unsupported character: \\%s", token)
+		return nil, syntaxError(line, col, fmt.Errorf("unsupported character: \\%s", token))
 	}
 }
 
-func listReader(r *bufio.Reader) (any, error) {
+func listReader(rd *Reader, r io.RuneScanner) (any, error) {
 	var l []any
-	err := readDelimitedList(r, ')', func(item any) {
+	err := rd.readDelimitedList(r, ')', func(item any) {
 		l = append(l, item)
 	})
 	return List(l), err
 }
 
-func vectorReader(r *bufio.Reader) (any, error) {
+func vectorReader(rd *Reader, r io.RuneScanner) (any, error) {
 	var l []any
-	err := readDelimitedList(r, ']', func(item any) {
+	err := rd.readDelimitedList(r, ']', func(item any) {
 		l = append(l, item)
 	})
 	return l, err
 }
 
-func mapReader(r *bufio.Reader) (any, error) {
-	m := make(map[any]any)
-	var key any
-	err := readDelimitedList(r, '}', func(item any) {
-		if key == nil {
-			key = item
-		} else {
-			m[key] = item
-			key = nil
-		}
+// a map literal with keys that can only be used once they have been
+// evaluated, like {(quote a) 1}, stored as alternating keys and values
+type mapForm []any
+
+func mapReader(rd *Reader, r io.RuneScanner) (any, error) {
+	var pairs []any
+	err := rd.readDelimitedList(r, '}', func(item any) {
+		pairs = append(pairs, item)
 	})
-	if key != nil {
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs)%2 != 0 {
 		return nil, fmt.Errorf("map[any]any literal must contain an even number of forms")
 	}
-	return m, err
+
+	m := make(map[any]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		if !isHashable(pairs[i]) {
+			if rd.edn {
+				return nil, fmt.Errorf("map key must be a hashable value: %v", pairs[i])
+			}
+			return mapForm(pairs), nil
+		}
+		m[pairs[i]] = pairs[i+1]
+	}
+	return m, nil
 }
 
-func unmatchedDelimiterReader(r *bufio.Reader) (any, error) {
+func isHashable(val any) bool {
+	return val == nil || reflect.TypeOf(val).Comparable()
+}
+
+func unmatchedDelimiterReader(rd *Reader, r io.RuneScanner) (any, error) {
 	return nil, errors.New("unmatched delimter")
 }
 
-func readDelimitedList(r *bufio.Reader, delim rune, add func(any)) error {
+func (rd *Reader) readDelimitedList(r io.RuneScanner, delim rune, add func(any)) error {
+	// the depth is left as is on errors, to skip the rest of the form
+	nest(r, 1)
 	for {
 		ch, _, err := r.ReadRune()
 
@@ -259,27 +602,28 @@ func readDelimitedList(r *bufio.Reader, delim rune, add func(any)) error {
 		}
 
 		if err != nil {
-			return err
+			return unexpectedEOF(err)
 		}
 
 		if ch == delim {
+			nest(r, -1)
 			break
 		}
 
-		macroFn, isMacro := macros[ch]
+		macroFn, isMacro := rd.macros[ch]
 		if isMacro {
-			mret, err := macroFn(r)
+			mret, err := macroFn(rd, r)
 			if err != nil {
-				return err
+				return unexpectedEOF(err)
 			}
 			if mret != r {
 				add(mret)
 			}
 		} else {
 			r.UnreadRune()
-			o, err := Read(r)
+			o, err := rd.Read(r)
 			if err != nil {
-				return err
+				return unexpectedEOF(err)
 			}
 			if o != r {
 				add(o)
@@ -289,3 +633,427 @@ func readDelimitedList(r *bufio.Reader, delim rune, add func(any)) error {
 
 	return nil
 }
+
+// read the dispatch macro or tagged literal that follows #
+func dispatchReader(rd *Reader, r io.RuneScanner) (any, error) {
+	line, col := lastPosition(r)
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	macroFn, isMacro := rd.dispatch[ch]
+	if isMacro {
+		ret, err := macroFn(rd, r)
+		if ret == r {
+			return ret, err
+		}
+		return ret, syntaxError(line, col, err)
+	}
+	if !unicode.IsLetter(ch) {
+		return nil, syntaxError(line, col, fmt.Errorf("no dispatch macro for: #%s", string(ch)))
+	}
+
+	r.UnreadRune()
+	ret, err := taggedLiteral(rd, r)
+	return ret, syntaxError(line, col, err)
+}
+
+// read a value like #inst "2020-01-01" using the handler for its tag
+func taggedLiteral(rd *Reader, r io.RuneScanner) (any, error) {
+	tag, err := rd.Read(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	sym, isSym := tag.(Symbol)
+	if !isSym {
+		return nil, fmt.Errorf("reader tag must be a symbol: %s", Print(tag))
+	}
+	handler, exists := rd.tags[sym]
+	if !exists {
+		handler, exists = rd.dataReader(sym)
+	}
+	if !exists {
+		handler, exists = rd.recordReader(sym)
+	}
+	if !exists {
+		return nil, fmt.Errorf("no reader function for tag: %s", sym)
+	}
+
+	val, err := rd.Read(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return handler(val)
+}
+
+// a set literal with items that can only be put in a set once they have
+// been evaluated, like #{(+ 1 2)}
+type setForm []any
+
+// find a lisp function for a tag in *data-readers*
+func (rd *Reader) dataReader(tag Symbol) (TagReader, bool) {
+	if rd.env == nil {
+		return nil, false
+	}
+	val, err := rd.env.Find(Symbol("*data-readers*"))
+	if err != nil {
+		return nil, false
+	}
+	readers, isMap := val.(map[any]any)
+	if !isMap {
+		return nil, false
+	}
+	fn, exists := readers[tag]
+	if !exists {
+		return nil, false
+	}
+	return func(val any) (any, error) {
+		return invoke(fn, []any{val})
+	}, true
+}
+
+func setReader(rd *Reader, r io.RuneScanner) (any, error) {
+	var items []any
+	err := rd.readDelimitedList(r, '}', func(item any) {
+		items = append(items, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := make(Set, len(items))
+	for _, item := range items {
+		if !isHashable(item) {
+			if rd.edn {
+				return nil, fmt.Errorf("set item must be a hashable value: %v", Print(item))
+			}
+			return setForm(items), nil
+		}
+		if _, exists := s[item]; exists {
+			return nil, fmt.Errorf("duplicate item in set: %v", Print(item))
+		}
+		s[item] = struct{}{}
+	}
+	return s, nil
+}
+
+// ##Inf, ##-Inf and ##NaN are the floats that have no number syntax
+func symbolicValueReader(rd *Reader, r io.RuneScanner) (any, error) {
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	token, err := rd.readToken(r, ch)
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return nil, fmt.Errorf("unknown symbolic value: ##%s", token)
+}
+
+// #_ reads and ignores the next form
+func discardReader(rd *Reader, r io.RuneScanner) (any, error) {
+	if _, err := rd.Read(r); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return r, nil
+}
+
+// the input ended in the middle of a form
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return ErrIncomplete
+	}
+	return err
+}
+
+// read the next form wrapped in a call, e.g. 'x is (quote x)
+func wrapReader(sym Symbol) ReaderMacro {
+	return func(rd *Reader, r io.RuneScanner) (any, error) {
+		val, err := rd.Read(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return List{sym, val}, nil
+	}
+}
+
+// ^meta form reads the metadata and ignores it, returning the form
+func metaReader(rd *Reader, r io.RuneScanner) (any, error) {
+	meta, err := rd.Read(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	switch meta.(type) {
+	case map[any]any, mapForm, Keyword, Symbol, string:
+	default:
+		return nil, fmt.Errorf("metadata must be a map, keyword, symbol or string: %s", Print(meta))
+	}
+
+	val, err := rd.Read(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return val, nil
+}
+
+// #(+ % %2) is a function literal for (fn [%1 %2] (+ %1 %2)), where %&
+// collects any remaining args
+func fnLiteralReader(rd *Reader, r io.RuneScanner) (any, error) {
+	body, err := listReader(rd, r)
+	if err != nil {
+		return nil, err
+	}
+
+	lit := &fnLiteral{}
+	expanded, err := lit.walk(body)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []any{}
+	for i := 1; i <= lit.nargs; i++ {
+		params = append(params, Symbol(fmt.Sprintf("%%%d", i)))
+	}
+	if lit.rest {
+		params = append(params, Symbol("&"), Symbol("%&"))
+	}
+	return List{Symbol("fn"), params, expanded}, nil
+}
+
+// the args used in the body of a function literal
+type fnLiteral struct {
+	nargs int
+	rest  bool
+}
+
+// replace % with %1 and find the args that the body uses
+func (lit *fnLiteral) walk(val any) (any, error) {
+	switch t := val.(type) {
+	case Symbol:
+		return lit.arg(t)
+	case List:
+		if len(t) > 1 && t[0] == Symbol("fn") && lit.hasArgParams(t[1]) {
+			return nil, fmt.Errorf("nested #()s are not allowed")
+		}
+		items, err := lit.walkSlice(t)
+		return List(items), err
+	case []any:
+		return lit.walkSlice(t)
+	case mapForm:
+		items, err := lit.walkSlice(t)
+		return mapForm(items), err
+	case setForm:
+		items, err := lit.walkSlice(t)
+		return setForm(items), err
+	case map[any]any:
+		pairs, err := lit.walkSlice(mapPairs(t))
+		if err != nil {
+			return nil, err
+		}
+		return buildMap(pairs)
+	case Set:
+		items, err := lit.walkSlice(setItems(t))
+		if err != nil {
+			return nil, err
+		}
+		return buildSet(items)
+	}
+	return val, nil
+}
+
+func (lit *fnLiteral) walkSlice(val []any) ([]any, error) {
+	ret := make([]any, len(val))
+	for i, v := range val {
+		w, err := lit.walk(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = w
+	}
+	return ret, nil
+}
+
+func (lit *fnLiteral) arg(sym Symbol) (any, error) {
+	if len(sym) == 0 || sym[0] != '%' {
+		return sym, nil
+	}
+	switch sym {
+	case "%":
+		sym = "%1"
+	case "%&":
+		lit.rest = true
+		return sym, nil
+	}
+
+	n, err := strconv.Atoi(string(sym[1:]))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("arg literal must be %%, %%& or %%integer: %s", sym)
+	}
+	if n > lit.nargs {
+		lit.nargs = n
+	}
+	return sym, nil
+}
+
+// whether the params of a fn form came from a function literal
+func (lit *fnLiteral) hasArgParams(params any) bool {
+	vect, isVect := params.([]any)
+	if !isVect {
+		return false
+	}
+	for _, p := range vect {
+		if sym, isSym := p.(Symbol); isSym && len(sym) > 0 && sym[0] == '%' {
+			return true
+		}
+	}
+	return false
+}
+
+// a rune reader that keeps track of the position in its input
+type scanner struct {
+	r io.RuneScanner
+	// the position of the next rune, with its byte offset
+	line, col, offset int
+	// the position of the last rune read, to restore on unread
+	lastLine, lastCol, lastOffset int
+	canUnread                     bool
+	// the start of the top level form being read
+	formLine, formCol, formOffset int
+	// the number of reads in progress, and the delimiters and strings
+	// they have left open
+	nested, depth int
+	inString      bool
+}
+
+func newScanner(r io.RuneScanner) *scanner {
+	return &scanner{r: r, line: 1, col: 1}
+}
+
+func (s *scanner) ReadRune() (rune, int, error) {
+	ch, size, err := s.r.ReadRune()
+	if err != nil {
+		s.canUnread = false
+		return ch, size, err
+	}
+	s.lastLine, s.lastCol, s.lastOffset = s.line, s.col, s.offset
+	s.canUnread = true
+	s.offset += size
+	if ch == '\n' {
+		s.line++
+		s.col = 1
+	} else {
+		s.col++
+	}
+	return ch, size, nil
+}
+
+func (s *scanner) UnreadRune() error {
+	if !s.canUnread {
+		return bufio.ErrInvalidUnreadRune
+	}
+	if err := s.r.UnreadRune(); err != nil {
+		return err
+	}
+	s.line, s.col, s.offset = s.lastLine, s.lastCol, s.lastOffset
+	s.canUnread = false
+	return nil
+}
+
+// report an error from a top level read, skipping the rest of the form
+// so the next read can start fresh
+func (s *scanner) recover(err error) error {
+	if err != io.EOF && !errors.Is(err, ErrIncomplete) {
+		s.skipForm()
+	}
+	s.depth, s.inString = 0, false
+	if err == io.EOF {
+		return err
+	}
+	if errors.Is(err, ErrIncomplete) {
+		return &SyntaxError{s.formLine, s.formCol, ErrIncomplete}
+	}
+	return syntaxError(s.formLine, s.formCol, err)
+}
+
+// skip to the end of the string and delimiters left open by an error
+func (s *scanner) skipForm() {
+	inString := s.inString
+	for s.depth > 0 || inString {
+		ch, _, err := s.ReadRune()
+		if err != nil {
+			return
+		}
+		switch {
+		case inString && ch == '\\':
+			s.ReadRune()
+		case inString:
+			inString = ch != '"'
+		case ch == '"':
+			inString = true
+		case ch == '\\':
+			s.ReadRune()
+		case ch == ';':
+			for err == nil && ch != '\n' {
+				ch, _, err = s.ReadRune()
+			}
+		case ch == '(' || ch == '[' || ch == '{':
+			s.depth++
+		case ch == ')' || ch == ']' || ch == '}':
+			s.depth--
+		}
+	}
+}
+
+// track the delimiters a read has open
+func nest(r io.RuneScanner, delta int) {
+	if s, isScanner := r.(*scanner); isScanner {
+		s.depth += delta
+	}
+}
+
+// track whether a read is inside of a string
+func setInString(r io.RuneScanner, inString bool) {
+	if s, isScanner := r.(*scanner); isScanner {
+		s.inString = inString
+	}
+}
+
+// A SyntaxError is a malformed literal at a position in the input
+type SyntaxError struct {
+	Line, Column int
+	Err          error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v at line %d, column %d", e.Err, e.Line, e.Column)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// the position of the last rune read, when reading from a scanner
+func lastPosition(r io.RuneScanner) (line, col int) {
+	if s, isScanner := r.(*scanner); isScanner {
+		return s.lastLine, s.lastCol
+	}
+	return 0, 0
+}
+
+// report an error at a position, unless it already has one
+func syntaxError(line, col int, err error) error {
+	var serr *SyntaxError
+	if err == nil || line == 0 || errors.As(err, &serr) || errors.Is(err, ErrIncomplete) {
+		return err
+	}
+	return &SyntaxError{line, col, err}
+}
@@ -2,9 +2,15 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNumbers(t *testing.T) {
@@ -12,6 +18,121 @@ func TestNumbers(t *testing.T) {
 	testRead(t, "7", 7)
 	testRead(t, "  7   ", 7)
 	testRead(t, "-123", -123)
+	testRead(t, "+5", 5)
+	testRead(t, "1.5e3", 1500.0)
+	testRead(t, "0x1F", 31)
+	testRead(t, "-0xff", -255)
+	testRead(t, "2r1010", 10)
+	testRead(t, "36rZZ", 1295)
+	testRead(t, "4/2", 2)
+	testRead(t, "-1/3", big.NewRat(-1, 3))
+	testRead(t, "7N", big.NewInt(7))
+	testRead(t, "1.5M", big.NewFloat(1.5))
+	testRead(t, "##Inf", math.Inf(1))
+	testRead(t, "##-Inf", math.Inf(-1))
+	if val, err := read("##NaN"); err != nil || !math.IsNaN(val.(float64)) {
+		t.Errorf("Expected: NaN\nActual: %v %v\n", val, err)
+	}
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	testRead(t, "123456789012345678901234567890", huge)
+
+	testReadError(t, "0xZZ")
+	testReadError(t, "1/0")
+	testReadError(t, "1/2.5")
+	testReadError(t, "37r1")
+	testReadError(t, "1_000")
+	testReadError(t, "0x1p3")
+	testReadError(t, "##Foo")
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	testSyntaxError(t, "0xZZ", 1, 1)
+	testSyntaxError(t, "(1 0xZZ)", 1, 4)
+	testSyntaxError(t, "[1\n  2 \\bogus]", 2, 5)
+	testSyntaxError(t, "\"ab\\qc\"", 1, 4)
+}
+
+func TestIncomplete(t *testing.T) {
+	for _, input := range []string{"(1 2", "[1 (2", `"abc`, `"ab\`, `\`, "#", "#{1", "'", "#_", "(1 ; comment"} {
+		_, err := read(input)
+		if !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Expected: ErrIncomplete for %q\nActual: %v\n", input, err)
+		}
+	}
+	for _, input := range []string{")", "(1 0xZZ", "[1 2)"} {
+		_, err := read(input)
+		if err == nil || errors.Is(err, ErrIncomplete) {
+			t.Errorf("Expected: syntax error for %q\nActual: %v\n", input, err)
+		}
+	}
+	_, err := read("")
+	if err != io.EOF {
+		t.Errorf("Expected: EOF\nActual: %v\n", err)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader(`(1 0xZZ (2 "3)")) [4] ("a\9 )" \) ; )
+		5) {:a #bogus 1 "}"} 6 ]`))
+	expected := []any{nil, []any{4}, nil, nil, 6, nil}
+	for _, output := range expected {
+		actual, err := Read(in)
+		if output == nil {
+			if err == nil {
+				t.Errorf("Expected: Error\nActual: %v\n", Print(actual))
+			}
+			continue
+		}
+		if err != nil || !Equals(actual, output) {
+			t.Errorf("\nExpected: %v\nActual: %v %v\n", Print(output), Print(actual), err)
+		}
+	}
+	if _, err := Read(in); err != io.EOF {
+		t.Errorf("Expected: EOF\nActual: %v\n", err)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	forms, err := ReadAll(strings.NewReader("1 ; one\n  (+ 2\n 3)\n'x #_ 4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Form{
+		{1, 1, 1},
+		{List{Symbol("+"), 2, 3}, 2, 3},
+		{List{Symbol("quote"), Symbol("x")}, 4, 1},
+	}
+	if len(forms) != len(expected) {
+		t.Fatalf("Expected: %d forms\nActual: %d\n", len(expected), len(forms))
+	}
+	for i, form := range forms {
+		if !Equals(form.Value, expected[i].Value) || form.Line != expected[i].Line || form.Column != expected[i].Column {
+			t.Errorf("\nExpected: %v\nActual: %v\n", expected[i], form)
+		}
+	}
+
+	forms, err = ReadAll(strings.NewReader("1 2\n(3"))
+	if len(forms) != 2 || !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected: 2 forms and ErrIncomplete\nActual: %v %v\n", forms, err)
+	}
+	var serr *SyntaxError
+	if !errors.As(err, &serr) || serr.Line != 2 || serr.Column != 1 {
+		t.Errorf("Expected: incomplete form at 2:1\nActual: %v\n", err)
+	}
+}
+
+func testSyntaxError(t *testing.T, input string, line, column int) {
+	t.Helper()
+	_, err := read(input)
+	var serr *SyntaxError
+	if !errors.As(err, &serr) {
+		t.Errorf("Expected: SyntaxError for %q\nActual: %v\n", input, err)
+		return
+	}
+	if serr.Line != line || serr.Column != column {
+		t.Errorf("Expected: %d:%d for %q\nActual: %d:%d\n", line, column, input, serr.Line, serr.Column)
+	}
 }
 
 func TestSymbols(t *testing.T) {
@@ -100,6 +221,15 @@ func TestCharacters(t *testing.T) {
 	testRead(t, `\newline`, '\n')
 	testRead(t, `\tab`, '\t')
 	testRead(t, `\space`, ' ')
+	testRead(t, `\u00e9`, 'é')
+	testRead(t, `\o101`, 'A')
+	testRead(t, `\u`, 'u')
+	testRead(t, `\o`, 'o')
+	testRead(t, `"\u0041\101\0"`, "AA\x00")
+	testReadError(t, `\u00zz`)
+	testReadError(t, `\o400`)
+	testReadError(t, `"\u12"`)
+	testReadError(t, `"\9"`)
 }
 
 func TestErrors(t *testing.T) {
@@ -136,6 +266,9 @@ func TestMaps(t *testing.T) {
 	testRead(t, "{  :a  {:b   {  :cde     3   }  }}", map[any]any{Keyword("a"): map[any]any{Keyword("b"): map[any]any{Keyword("cde"): 3}}})
 	testRead(t, "{\"1\" 1}", map[any]any{"1": 1})
 	testRead(t, "({})", List{map[any]any{}})
+	// unhashable keys are kept as a form to be evaluated
+	testRead(t, "{(quote a) 1 :b 2}", mapForm{List{Symbol("quote"), Symbol("a")}, 1, Keyword("b"), 2})
+	testReadError(t, "{:a}")
 }
 
 func TestComments(t *testing.T) {
@@ -153,6 +286,188 @@ func TestComments(t *testing.T) {
 	testRead(t, "1;`", 1)
 }
 
+func TestSets(t *testing.T) {
+	testRead(t, "#{}", Set{})
+	testRead(t, "#{1 :a \"b\"}", Set{1: {}, Keyword("a"): {}, "b": {}})
+	testRead(t, "[#{1} #{}]", []any{Set{1: {}}, Set{}})
+	testReadError(t, "#{1 1}")
+	// unhashable items are kept as a form to be evaluated
+	testRead(t, "#{(+ 1 2) a}", setForm{List{Symbol("+"), 1, 2}, Symbol("a")})
+	testReadError(t, "#{1")
+}
+
+func TestDiscard(t *testing.T) {
+	testRead(t, "#_ 1 2", 2)
+	testRead(t, "[1 #_ 2 3]", []any{1, 3})
+	testRead(t, "[1 #_ (2 [3]) #_#_ 4 5]", []any{1})
+	testRead(t, "{:a #_ :b 1}", map[any]any{Keyword("a"): 1})
+	testReadError(t, "[1 #_]")
+}
+
+func TestTaggedLiterals(t *testing.T) {
+	testRead(t, `#inst "1985-04-12T23:20:50.52Z"`, time.Date(1985, 4, 12, 23, 20, 50, 520000000, time.UTC))
+	testRead(t, `#inst "1985-04-12"`, time.Date(1985, 4, 12, 0, 0, 0, 0, time.UTC))
+	testRead(t, `#inst "1985-04-12T19:20:50-04:00"`, time.Date(1985, 4, 12, 23, 20, 50, 0, time.UTC))
+	testRead(t, `#uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bf6"`,
+		UUID{0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6})
+	testRead(t, `[#inst "2020" #_ #inst "2021"]`, []any{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)})
+	// discarded forms still have to be valid
+	testReadError(t, `[#_ #uuid "x"]`)
+	testReadError(t, `#inst "yesterday"`)
+	testReadError(t, `#inst 1`)
+	testReadError(t, `#uuid "f81d4fae"`)
+	testReadError(t, `#uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bfz"`)
+	testReadError(t, `#unknown 1`)
+	testReadError(t, `#inst`)
+	testReadError(t, `#!`)
+}
+
+func TestHashInSymbols(t *testing.T) {
+	testRead(t, "abc#", Symbol("abc#"))
+	testRead(t, "(a#b c)", List{Symbol("a#b"), Symbol("c")})
+}
+
+func TestShorthands(t *testing.T) {
+	testRead(t, "'a", List{Symbol("quote"), Symbol("a")})
+	testRead(t, "'(1 2)", List{Symbol("quote"), List{1, 2}})
+	testRead(t, "[a'b 'c]", []any{Symbol("a'b"), List{Symbol("quote"), Symbol("c")}})
+	testRead(t, "@x", List{Symbol("deref"), Symbol("x")})
+	testRead(t, "#'x", List{Symbol("var"), Symbol("x")})
+	testRead(t, "^:private x", Symbol("x"))
+	testRead(t, "^{:doc \"a\"} [x]", []any{Symbol("x")})
+	testRead(t, "^String ^:dynamic x", Symbol("x"))
+	testReadError(t, "'")
+	testReadError(t, "(@)")
+	testReadError(t, "^1 x")
+	testReadError(t, "^:private")
+}
+
+func TestFnLiterals(t *testing.T) {
+	testRead(t, "#(+ 1 2)", List{Symbol("fn"), []any{}, List{Symbol("+"), 1, 2}})
+	testRead(t, "#(+ % %)", List{Symbol("fn"), []any{Symbol("%1")}, List{Symbol("+"), Symbol("%1"), Symbol("%1")}})
+	testRead(t, "#(list %3 %1)", List{Symbol("fn"), []any{Symbol("%1"), Symbol("%2"), Symbol("%3")},
+		List{Symbol("list"), Symbol("%3"), Symbol("%1")}})
+	testRead(t, "#(f % %&)", List{Symbol("fn"), []any{Symbol("%1"), Symbol("&"), Symbol("%&")},
+		List{Symbol("f"), Symbol("%1"), Symbol("%&")}})
+	testRead(t, "#(vector [%] {:a %2})", List{Symbol("fn"), []any{Symbol("%1"), Symbol("%2")},
+		List{Symbol("vector"), []any{Symbol("%1")}, map[any]any{Keyword("a"): Symbol("%2")}}})
+	testReadError(t, "#(f #(g %))")
+	testReadError(t, "#(f %x)")
+	testReadError(t, "#(f %0)")
+	testReadError(t, "#(f %")
+	// edn has none of the shorthands
+	testReaderRead(t, ednReader, "'a", Symbol("'a"))
+	testReaderError(t, ednReader, "#(f %)")
+	testReaderError(t, ednReader, "#'a")
+}
+
+type testMoney struct {
+	Cents    int
+	Currency string
+}
+
+func parseTestMoney(val any) (any, error) {
+	s, isStr := val.(string)
+	if !isStr {
+		return nil, fmt.Errorf("#money expects a string")
+	}
+	var dollars, cents int
+	var currency string
+	if _, err := fmt.Sscanf(s, "%d.%d %s", &dollars, &cents, &currency); err != nil {
+		return nil, err
+	}
+	return testMoney{dollars*100 + cents, currency}, nil
+}
+
+func TestRegisterTag(t *testing.T) {
+	rd := NewReader(NewEnv())
+	rd.RegisterTag("money", parseTestMoney)
+	rd.RegisterTag("my/money", parseTestMoney)
+
+	testReaderRead(t, rd, `#money "12.50 USD"`, testMoney{1250, "USD"})
+	testReaderRead(t, rd, `[#my/money "1.05 EUR"]`, []any{testMoney{105, "EUR"}})
+	testReaderError(t, rd, `#money 12`)
+	testReaderError(t, rd, `#money "abc"`)
+
+	// tags are only registered for one reader
+	testReadError(t, `#money "12.50 USD"`)
+	testReaderError(t, NewReader(NewEnv()), `#money "12.50 USD"`)
+}
+
+func TestRegisterDispatch(t *testing.T) {
+	rd := NewReader(NewEnv())
+	err := rd.RegisterDispatch('?', func(rd *Reader, r io.RuneScanner) (any, error) {
+		val, err := rd.Read(r)
+		if err != nil {
+			return nil, err
+		}
+		return List{Symbol("maybe"), val}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testReaderRead(t, rd, "#?[1]", List{Symbol("maybe"), []any{1}})
+	testReaderRead(t, rd, "(#?a)", List{List{Symbol("maybe"), Symbol("a")}})
+	testReadError(t, "#?[1]")
+
+	if rd.RegisterDispatch('m', nil) == nil {
+		t.Error("expected letters to be reserved for tags")
+	}
+}
+
+func TestDataReaders(t *testing.T) {
+	env := ChildEnv(NewEnv())
+	env.Define(Symbol("parse-money"), gofunc(parseTestMoney))
+	rd := NewReader(env)
+
+	testReaderError(t, rd, `#money "12.50 USD"`)
+	if _, err := readEvalWith(rd, `(def *data-readers* {(quote money) parse-money})`, env); err != nil {
+		t.Fatal(err)
+	}
+	testReaderRead(t, rd, `#money "12.50 USD"`, testMoney{1250, "USD"})
+
+	if _, err := readEvalWith(rd, `(def *data-readers* {(quote twice) (fn [x] (* 2 x))})`, env); err != nil {
+		t.Fatal(err)
+	}
+	testReaderRead(t, rd, "#twice 21", 42)
+	testReaderError(t, rd, `#twice "a"`)
+
+	// registered tags take precedence
+	rd.RegisterTag("twice", func(val any) (any, error) {
+		return val, nil
+	})
+	testReaderRead(t, rd, "#twice 21", 21)
+}
+
+func testReaderRead(t *testing.T, rd *Reader, input string, output any) {
+	t.Helper()
+	actual, err := rd.Read(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Errorf("\nInput: %s\nActual: Error - %s\n", input, err)
+		return
+	}
+	if !Equals(actual, output) {
+		t.Errorf("\nInput: %s\nExpected: %v\nActual: %v\n", input, Print(output), Print(actual))
+	}
+}
+
+func testReaderError(t *testing.T, rd *Reader, input string) {
+	t.Helper()
+	actual, err := rd.Read(bufio.NewReader(strings.NewReader(input)))
+	if err == nil {
+		t.Errorf("\nInput: %s\nExpected: Error\nActual: %v\n", input, Print(actual))
+	}
+}
+
+func readEvalWith(rd *Reader, input string, env *Env) (any, error) {
+	val, err := rd.Read(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		return nil, err
+	}
+	return Eval(val, env)
+}
+
 func testRead(t *testing.T, input string, output any) {
 	actual, err := read(input)
 	if err != nil {
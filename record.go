@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A record type is a named set of fields, created by defrecord
+type recordType struct {
+	name   string
+	fields []Keyword
+}
+
+// a record is a map with the fields of its type, along with any other keys
+type record struct {
+	rtype *recordType
+	vals  map[any]any
+}
+
+// records print with this namespace, like #user.Point{:x 1, :y 2}
+const recordNamespace = "user."
+
+// (defrecord Name [fields...] Protocol (method [this] body...) ...) defines a
+// record type along with its constructors ->Name and map->Name, which are
+// always global. The fields are in scope in the bodies of methods.
+func defrecord(args []any, sc *scope, tail bool) (compiled, error) {
+	if err := checkArity("defrecord", args, 2, -1); err != nil {
+		return nil, err
+	}
+	sym, isSym := args[0].(Symbol)
+	if !isSym {
+		return nil, fmt.Errorf("first argument to defrecord must be a Symbol")
+	}
+	vect, isVect := args[1].([]any)
+	if !isVect {
+		return nil, fmt.Errorf("second argument to defrecord must be a vector of fields")
+	}
+	fields := make([]Symbol, len(vect))
+	for i, v := range vect {
+		field, isSym := v.(Symbol)
+		if !isSym || field == "&" {
+			return nil, fmt.Errorf("invalid record field: %s", Print(v))
+		}
+		fields[i] = field
+	}
+
+	impls, err := analyzeImpls("defrecord", args[2:], sc, fields)
+	if err != nil {
+		return nil, err
+	}
+	set := globalDefiner(sym, sc)
+	setPositional := globalDefiner("->"+sym, sc)
+	setFromMap := globalDefiner("map->"+sym, sc)
+	return func(env *Env) (any, error) {
+		rt := &recordType{name: string(sym), fields: make([]Keyword, len(fields))}
+		for i, field := range fields {
+			rt.fields[i] = Keyword(field)
+		}
+		set(env, rt)
+		setPositional(env, primitive(rt.positional))
+		setFromMap(env, primitive(rt.fromMapPrim))
+		return sym, extendImpls(env, rt, impls)
+	}, nil
+}
+
+// (->Name field-values...)
+func (rt *recordType) positional(args []any) (any, error) {
+	if len(args) != len(rt.fields) {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to ->%s", len(args), rt.name)
+	}
+	vals := make(map[any]any, len(args))
+	for i, field := range rt.fields {
+		vals[field] = args[i]
+	}
+	return &record{rt, vals}, nil
+}
+
+// (map->Name m), where missing fields are nil
+func (rt *recordType) fromMapPrim(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to map->%s", len(args), rt.name)
+	}
+	m, isMap := args[0].(map[any]any)
+	if !isMap {
+		return nil, fmt.Errorf("map->%s expects a map: %s", rt.name, Print(args[0]))
+	}
+	return rt.fromMap(m), nil
+}
+
+func (rt *recordType) fromMap(m map[any]any) *record {
+	vals := make(map[any]any, len(m)+len(rt.fields))
+	for _, field := range rt.fields {
+		vals[field] = nil
+	}
+	for k, v := range m {
+		vals[k] = v
+	}
+	return &record{rt, vals}
+}
+
+// wrap the implementation of a method in defrecord, which takes the values
+// of the fields before the args
+func (rt *recordType) withFields(fn any) tailPrimitive {
+	return func(args []any) (any, []any, error) {
+		r := args[0].(*record)
+		withFields := make([]any, 0, len(rt.fields)+len(args))
+		for _, field := range rt.fields {
+			withFields = append(withFields, r.vals[field])
+		}
+		return fn, append(withFields, args...), nil
+	}
+}
+
+// the tag that records of a type are printed and read with
+func recordTag(rt *recordType) string {
+	return recordNamespace + rt.name
+}
+
+// the fields of a record in order, then its other keys
+func (r *record) pairs() []any {
+	pairs := make([]any, 0, 2*len(r.vals))
+	extra := make(map[any]any, len(r.vals))
+	for k, v := range r.vals {
+		extra[k] = v
+	}
+	for _, field := range r.rtype.fields {
+		pairs = append(pairs, field, r.vals[field])
+		delete(extra, field)
+	}
+	return append(pairs, mapPairs(extra)...)
+}
+
+func recordEquals(r1, r2 *record) bool {
+	return r1.rtype == r2.rtype && len(r1.vals) == len(r2.vals) && mapEquals(r1.vals, r2.vals)
+}
+
+// find the record type for a tag like user.Point, so that printed records
+// can be read back
+func (rd *Reader) recordReader(tag Symbol) (TagReader, bool) {
+	if rd.env == nil || !strings.HasPrefix(string(tag), recordNamespace) {
+		return nil, false
+	}
+	val, err := rd.env.Find(Symbol(strings.TrimPrefix(string(tag), recordNamespace)))
+	if err != nil {
+		return nil, false
+	}
+	rt, isRecord := val.(*recordType)
+	if !isRecord {
+		return nil, false
+	}
+	return func(val any) (any, error) {
+		if pairs, isForm := val.(mapForm); isForm {
+			m, err := buildMap(pairs)
+			if err != nil {
+				return nil, err
+			}
+			val = m
+		}
+		m, isMap := val.(map[any]any)
+		if !isMap {
+			return nil, fmt.Errorf("record literal must be a map: %s", Print(val))
+		}
+		return rt.fromMap(m), nil
+	}, true
+}
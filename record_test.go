@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const points = `
+	(defprotocol Shape (area [this]) (scale [this n]))
+	(defrecord Point [x y]
+		Shape
+		(area [this] 0)
+		(scale [this n] (->Point (* x n) (* y n))))
+`
+
+func TestRecords(t *testing.T) {
+	testEval(t, points+"((->Point 1 2) :x)", 1)
+	testEval(t, points+"((map->Point {:x 1 :y 2}) :y)", 2)
+	testEval(t, points+"((map->Point {:x 1}) :y)", nil)
+	testEval(t, points+"((map->Point {:x 1 :z 3}) :z)", 3)
+	testEval(t, points+"(= (->Point 1 2) (map->Point {:x 1 :y 2}))", true)
+	testEval(t, points+"(= (->Point 1 2) (->Point 2 1))", false)
+	testEval(t, points+"(= (->Point 1 2) {:x 1 :y 2})", false)
+	testEval(t, points+"(defrecord Other [x y]) (= (->Point 1 2) (->Other 1 2))", false)
+	testEval(t, points+"(= (type (->Point 1 2)) Point)", true)
+
+	// protocols, with the fields in scope of inline methods
+	testEval(t, points+"(area (->Point 1 2))", 0)
+	testEval(t, points+"((scale (->Point 1 2) 3) :y)", 6)
+	testEval(t, points+"(satisfies? Shape (->Point 1 2))", true)
+	testEval(t, points+"(defrecord Size [w h]) (extend-type Size Shape (area [s] (* (s :w) (s :h)))) (area (->Size 2 3))", 6)
+	testEval(t, points+"(defrecord Size [w h] Shape (area [this] (* w h))) (area (->Size 2 3))", 6)
+	testEval(t, points+"(defmulti kind type) (defmethod kind Point [p] :point) (kind (->Point 1 2))", Keyword("point"))
+
+	testEvalError(t, points+"(->Point 1)")
+	testEvalError(t, points+"(map->Point [1 2])")
+	testEvalError(t, points+"((->Point 1 2) :z)")
+	testEvalError(t, points+"(defrecord Size [w h]) (area (->Size 2 3))")
+	testEvalError(t, "(defrecord Point [x 1])")
+	testEvalError(t, "(defrecord Point x)")
+	testEvalError(t, "(defprotocol Shape (area [this])) (defrecord Point [x y] Shape (perimeter [this] 0))")
+}
+
+func TestPrintRecords(t *testing.T) {
+	env := NewEnv()
+	if _, err := readEval(points, env); err != nil {
+		t.Fatal(err)
+	}
+	for input, output := range map[string]string{
+		"(->Point 1 2)":              "#user.Point{:x 1, :y 2}",
+		"(map->Point {:y 2 :z [3]})": "#user.Point{:x nil, :y 2, :z [3]}",
+		"Point":                      "#<type user.Point>",
+	} {
+		val, err := readEval(input, env)
+		if err != nil || Print(val) != output {
+			t.Errorf("\nExpected: %s\nActual: %s %v\n", output, Print(val), err)
+		}
+	}
+
+	// records can be read back in the environment that defines them
+	for _, input := range []string{"(->Point 1 2)", "(map->Point {:y 2 :z [3]})"} {
+		val, err := readEval(input, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := NewReader(env).Read(strings.NewReader(Print(val)))
+		if err != nil || !Equals(read, val) {
+			t.Errorf("\nExpected: %s\nActual: %s %v\n", Print(val), Print(read), err)
+		}
+	}
+	if _, err := NewReader(NewEnv()).Read(strings.NewReader("#user.Point{:x 1}")); err == nil {
+		t.Errorf("Expected: Error for an undefined record")
+	}
+
+	val, err := readEval("(json/write (->Point 1 2))", env)
+	if err != nil || val != `{"x":1,"y":2}` {
+		t.Errorf("\nExpected: {\"x\":1,\"y\":2}\nActual: %v %v\n", val, err)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Create an environment for untrusted scripts, with only the builtins of
+// the allowed capabilities. Go packages with bindings can be allowed by
+// their import path, e.g. NewSandboxEnv("core", "json", "strings"), along
+// with the capability for any side effects they have, e.g. "fs" for
+// "path/filepath".
+func NewSandboxEnv(allowed ...string) (*Env, error) {
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		isAllowed[name] = true
+	}
+
+	symbols := make(map[Symbol]any)
+	for _, name := range allowed {
+		builtins, exists := capabilities[name]
+		if !exists {
+			builtins, exists = packages[name]
+			if exists {
+				if err := checkPackage(name, isAllowed); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !exists {
+			return nil, fmt.Errorf("unknown capability: %s", name)
+		}
+		for sym, val := range builtins {
+			symbols[sym] = val
+		}
+	}
+	return globalEnv(symbols, isAllowed), nil
+}
+
+// check that a sandbox allows the capability that a go package needs
+func checkPackage(pkg string, allowed map[string]bool) error {
+	needed, listed := packageCapabilities[pkg]
+	if !listed {
+		return fmt.Errorf("package can't be allowed in a sandbox: %s", pkg)
+	}
+	if needed != "" && !allowed[needed] {
+		return fmt.Errorf("package %s needs the %s capability", pkg, needed)
+	}
+	return nil
+}
+
+// the names of the capabilities that a sandbox can allow, not including
+// go packages
+func Capabilities() []string {
+	ret := make([]string, 0, len(capabilities))
+	for name := range capabilities {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// the capability or go package that binds a symbol
+func capabilityOf(sym Symbol) (string, bool) {
+	for name, builtins := range capabilities {
+		if _, exists := builtins[sym]; exists {
+			return name, true
+		}
+	}
+	for pkg, bindings := range packages {
+		if _, exists := bindings[sym]; exists {
+			return pkg, true
+		}
+	}
+	return "", false
+}
+
+// An AuditReport lists what a script needs from its environment
+type AuditReport struct {
+	// the free symbols of the script, by the capability that binds them
+	Capabilities map[string][]Symbol
+	// free symbols that no capability binds
+	Unresolved []Symbol
+}
+
+// Audit a script without running it, by finding the symbols it uses that
+// aren't params or defined by the script itself
+func Audit(r io.Reader) (*AuditReport, error) {
+	forms, err := ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &auditor{free: make(map[Symbol]bool), defined: make(map[Symbol]bool)}
+	for _, form := range forms {
+		a.walk(form.Value, nil)
+	}
+
+	report := &AuditReport{Capabilities: make(map[string][]Symbol)}
+	for sym := range a.free {
+		if a.defined[sym] {
+			continue
+		}
+		name, exists := capabilityOf(sym)
+		if exists {
+			report.Capabilities[name] = append(report.Capabilities[name], sym)
+		} else {
+			report.Unresolved = append(report.Unresolved, sym)
+		}
+	}
+	for _, syms := range report.Capabilities {
+		sortSymbols(syms)
+	}
+	sortSymbols(report.Unresolved)
+	return report, nil
+}
+
+func sortSymbols(syms []Symbol) {
+	sort.Slice(syms, func(i, j int) bool { return syms[i] < syms[j] })
+}
+
+type auditor struct {
+	free    map[Symbol]bool
+	defined map[Symbol]bool
+}
+
+// collect the free symbols of a form, where locals are the params in scope
+func (a *auditor) walk(form any, locals map[Symbol]bool) {
+	switch t := form.(type) {
+	case Symbol:
+		if !locals[t] {
+			a.free[t] = true
+		}
+	case List:
+		if len(t) == 0 {
+			return
+		}
+		if expanded, isInterop := expandInterop(t); isInterop {
+			t = expanded
+		}
+
+		head, _ := t[0].(Symbol)
+		if locals[head] {
+			// a param that shadows a special form is just a call
+			head = ""
+		}
+		switch head {
+		case "quote":
+			a.free[head] = true
+		case "def", "defn":
+			a.free[head] = true
+			if len(t) < 2 {
+				return
+			}
+			if sym, isSym := t[1].(Symbol); isSym {
+				a.defined[sym] = true
+			}
+			if head == "def" {
+				a.walkAll(t[2:], locals)
+			} else {
+				a.walkFn(t[2:], locals)
+			}
+		case "fn":
+			a.free[head] = true
+			a.walkFn(t[1:], locals)
+		case "loop":
+			a.free[head] = true
+			a.walkLoop(t[1:], locals)
+		case "defmulti":
+			a.free[head] = true
+			if len(t) < 2 {
+				return
+			}
+			if sym, isSym := t[1].(Symbol); isSym {
+				a.defined[sym] = true
+			}
+			a.walkAll(t[2:], locals)
+		case "defmethod":
+			a.free[head] = true
+			if len(t) < 3 {
+				a.walkAll(t[1:], locals)
+				return
+			}
+			a.walkAll(t[1:3], locals)
+			a.walkFn(t[3:], locals)
+		case "defprotocol":
+			// the signatures of methods only name params
+			a.free[head] = true
+			for _, arg := range t[1:] {
+				if sig, isList := arg.(List); isList && len(sig) > 0 {
+					arg = sig[0]
+				}
+				if sym, isSym := arg.(Symbol); isSym {
+					a.defined[sym] = true
+				}
+			}
+		case "extend-type":
+			a.free[head] = true
+			a.walkImpls(t[1:], locals)
+		case "defrecord":
+			a.free[head] = true
+			if len(t) < 3 {
+				return
+			}
+			if sym, isSym := t[1].(Symbol); isSym {
+				a.defined[sym] = true
+				a.defined["->"+sym] = true
+				a.defined["map->"+sym] = true
+			}
+			fields, _ := t[2].([]any)
+			inner := make(map[Symbol]bool, len(locals)+len(fields))
+			for sym := range locals {
+				inner[sym] = true
+			}
+			for _, field := range fields {
+				if sym, isSym := field.(Symbol); isSym {
+					inner[sym] = true
+				}
+			}
+			a.walkImpls(t[3:], inner)
+		default:
+			a.walkAll(t, locals)
+		}
+	case []any:
+		a.walkAll(t, locals)
+	case mapForm:
+		a.walkAll(t, locals)
+	case setForm:
+		a.walkAll(t, locals)
+	case map[any]any:
+		for k, v := range t {
+			a.walk(k, locals)
+			a.walk(v, locals)
+		}
+	case Set:
+		for item := range t {
+			a.walk(item, locals)
+		}
+	}
+}
+
+func (a *auditor) walkAll(forms []any, locals map[Symbol]bool) {
+	for _, form := range forms {
+		a.walk(form, locals)
+	}
+}
+
+// walk a params vector and body, e.g. the args to fn
+func (a *auditor) walkFn(args []any, locals map[Symbol]bool) {
+	if len(args) == 0 {
+		return
+	}
+	params, isVect := args[0].([]any)
+	if !isVect {
+		a.walkAll(args, locals)
+		return
+	}
+
+	inner := make(map[Symbol]bool, len(locals)+len(params))
+	for sym := range locals {
+		inner[sym] = true
+	}
+	for _, param := range params {
+		if sym, isSym := param.(Symbol); isSym {
+			inner[sym] = true
+		}
+	}
+	a.walkAll(args[1:], inner)
+}
+
+// walk the protocols and method implementations of extend-type or defrecord
+func (a *auditor) walkImpls(args []any, locals map[Symbol]bool) {
+	for _, arg := range args {
+		if impl, isList := arg.(List); isList && len(impl) > 0 {
+			a.walkFn(impl[1:], locals)
+		} else {
+			a.walk(arg, locals)
+		}
+	}
+}
+
+// walk the bindings and body of a loop, where each binding is in scope
+// for the ones after it
+func (a *auditor) walkLoop(args []any, locals map[Symbol]bool) {
+	if len(args) == 0 {
+		return
+	}
+	bindings, isVect := args[0].([]any)
+	if !isVect {
+		a.walkAll(args, locals)
+		return
+	}
+
+	inner := make(map[Symbol]bool, len(locals)+len(bindings)/2)
+	for sym := range locals {
+		inner[sym] = true
+	}
+	for i := 0; i+1 < len(bindings); i += 2 {
+		a.walk(bindings[i+1], inner)
+		if sym, isSym := bindings[i].(Symbol); isSym {
+			inner[sym] = true
+		}
+	}
+	a.walkAll(args[1:], inner)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandbox(t *testing.T) {
+	testSandboxEval(t, []string{"core"}, "(defn sq [x] (* x x)) (sq 5)", 25)
+	testSandboxEval(t, []string{"core", "json"}, `(json/write {:a 1})`, `{"a":1}`)
+	testSandboxEval(t, []string{"core", "strings"}, `(strings.ToUpper "abc")`, "ABC")
+
+	testSandboxError(t, []string{"core"}, "(exit 1)")
+	testSandboxError(t, []string{"core"}, `(fmt.Println "hi")`)
+	testSandboxError(t, []string{"core"}, `(json/write 1)`)
+	testSandboxError(t, []string{"json"}, "(+ 1 2)")
+
+	if _, err := NewSandboxEnv("core", "filesystem"); err == nil {
+		t.Errorf("Expected: Error for an unknown capability")
+	}
+}
+
+func TestSandboxPackages(t *testing.T) {
+	testSandboxEval(t, []string{"core", "path/filepath", "fs"}, `(filepath.Base "a/b")`, "b")
+	testSandboxEval(t, []string{"fs", "core", "path/filepath"}, `(filepath.Base "a/b")`, "b")
+	testSandboxEval(t, []string{"core", "fmt", "print"}, `(fmt.Sprint 1)`, "1")
+	testSandboxEval(t, []string{"core", "time", "clock"}, `(time.ParseDuration "90s")`, 90*time.Second)
+
+	// packages with side effects need their capability
+	for _, allowed := range [][]string{
+		{"core", "path/filepath"},
+		{"core", "time"},
+		{"core", "fmt"},
+	} {
+		if _, err := NewSandboxEnv(allowed...); err == nil {
+			t.Errorf("Expected: Error for %v\n", allowed)
+		}
+	}
+
+	// so a sandbox without the filesystem can't list directories
+	testSandboxError(t, []string{"core", "strings"}, `(filepath.Glob "*")`)
+	testSandboxError(t, []string{"core", "strings"}, `(filepath.WalkDir "." (fn [path d err] nil))`)
+
+	// including when they're imported afterwards
+	env, err := NewSandboxEnv("core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Import("path/filepath"); err == nil {
+		t.Errorf("Expected: Error importing path/filepath without fs")
+	}
+	if err := env.Import("strings"); err != nil {
+		t.Error(err)
+	}
+	env, err = NewSandboxEnv("core", "fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Import("path/filepath"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSandboxInterop(t *testing.T) {
+	env, err := NewSandboxEnv("core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Define(Symbol("p"), &testPoint{X: 1, Y: 2})
+	for _, input := range []string{
+		"(.Sum p)", "(.-X p)", "(set-field! p :X 5)", "(->map p)",
+		"(:X p)", "(p :X)", "(get p :X)",
+		"(get-in {:p p} [:p :X])", "({:p p} :p :X)", "(apply :X [p])",
+	} {
+		if _, err := readEval(input, env); err == nil {
+			t.Errorf("Expected: Error for %s\n", input)
+		}
+	}
+
+	env, err = NewSandboxEnv("core", "interop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Define(Symbol("p"), &testPoint{X: 1, Y: 2})
+	for input, expected := range map[string]any{"(.Sum p)": 3, "(:X p)": 1, "(p :Y)": 2, "(get p :X)": 1} {
+		val, err := readEval(input, env)
+		if err != nil || !Equals(val, expected) {
+			t.Errorf("\nany: %s\nExpected: %v\nActual: %v %v\n", input, expected, val, err)
+		}
+	}
+}
+
+func TestEnvsAreIndependent(t *testing.T) {
+	env := NewEnv()
+	if _, err := readEval("(def + -)", env); err != nil {
+		t.Fatal(err)
+	}
+	testEval(t, "(+ 1 2)", 3)
+}
+
+func TestAudit(t *testing.T) {
+	report, err := Audit(strings.NewReader(`
+		(defn total [items & more]
+			(fmt.Println (.String (first items)) more)
+			(total (json/read "[]")))
+		(def shout (fn [s] (strings.ToUpper s)))
+		'(exit 1)
+		#(* % factor)
+		(loop [i 0 j i] (if (< i limit) (recur (+ i 1) j) i))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]Symbol{
+		"core":    {"*", "+", "<", "def", "defn", "fn", "if", "loop", "quote", "recur"},
+		"interop": {"."},
+		"json":    {"json/read"},
+		"print":   {"fmt.Println"},
+		"strings": {"strings.ToUpper"},
+	}
+	if !reflect.DeepEqual(report.Capabilities, expected) {
+		t.Errorf("\nExpected: %v\nActual: %v\n", expected, report.Capabilities)
+	}
+	if !reflect.DeepEqual(report.Unresolved, []Symbol{"factor", "first", "limit"}) {
+		t.Errorf("\nExpected: [factor first limit]\nActual: %v\n", report.Unresolved)
+	}
+
+	if _, err := Audit(strings.NewReader("(+ 1")); err == nil {
+		t.Errorf("Expected: Error for an incomplete script")
+	}
+}
+
+func TestAuditDispatch(t *testing.T) {
+	report, err := Audit(strings.NewReader(`
+		(defmulti area :kind)
+		(defmethod area :square [s] (* side side))
+		(defprotocol Show (show [this]) (show-with [this prefix]))
+		(extend-type Int Show (show [n] (str n)))
+		(defrecord Point [x y] Show (show [this] (str x y)))
+		(show (->Point 1 2))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]Symbol{
+		"core": {"*", "Int", "defmethod", "defmulti", "defprotocol", "defrecord", "extend-type"},
+	}
+	if !reflect.DeepEqual(report.Capabilities, expected) {
+		t.Errorf("\nExpected: %v\nActual: %v\n", expected, report.Capabilities)
+	}
+	if !reflect.DeepEqual(report.Unresolved, []Symbol{"side", "str"}) {
+		t.Errorf("\nExpected: [side str]\nActual: %v\n", report.Unresolved)
+	}
+}
+
+func testSandboxEval(t *testing.T, allowed []string, input string, output any) {
+	t.Helper()
+	env, err := NewSandboxEnv(allowed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := readEval(input, env)
+	if err != nil {
+		t.Errorf("\nExpected: %v\nActual: Error - %s\n", Print(output), err)
+		return
+	}
+	if !Equals(actual, output) {
+		t.Errorf("\nExpected: %v\nActual: %v\n", Print(output), Print(actual))
+	}
+}
+
+func testSandboxError(t *testing.T, allowed []string, input string) {
+	t.Helper()
+	env, err := NewSandboxEnv(allowed...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := readEval(input, env); err == nil {
+		t.Errorf("Expected: Error for %s\nActual: %v\n", input, Print(actual))
+	}
+}
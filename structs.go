@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// a struct field as seen from lisp, named by its json tag if it has one
+type lispField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// the exported fields of a struct, including the fields of embedded structs
+func structFields(t reflect.Type) []lispField {
+	var ret []lispField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || (f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name := f.Name
+		if tag, hasTag := f.Tag.Lookup("json"); hasTag {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		ret = append(ret, lispField{name, f.Index, f.Type})
+	}
+	return ret
+}
+
+// find a field by name, preferring an exact match over a case insensitive one
+func findField(t reflect.Type, name string) (lispField, bool) {
+	fields := structFields(t)
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return lispField{}, false
+}
+
+// the struct that a value is or points to
+func asStruct(val any) (reflect.Value, bool) {
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
+
+// look up a key in a map, an index in a vector or a field in a struct,
+// where a key that can't be hashed is never in a map, and fields can only
+// be read with interop
+func lookup(val any, key any, interop bool) (any, bool, error) {
+	if m, isMap := val.(map[any]any); isMap {
+		if !isHashable(key) {
+			return nil, false, nil
+		}
+		ret, exists := m[key]
+		return ret, exists, nil
+	}
+	if v, isVect := val.([]any); isVect {
+		i, isInt := key.(int)
+		if !isInt {
+			return nil, false, fmt.Errorf("vector index must be an int: %s", Print(key))
+		}
+		if i < 0 || i >= len(v) {
+			return nil, false, nil
+		}
+		return v[i], true, nil
+	}
+	if r, isRecord := val.(*record); isRecord {
+		if !isHashable(key) {
+			return nil, false, nil
+		}
+		ret, exists := r.vals[key]
+		return ret, exists, nil
+	}
+
+	if s, isStruct := asStruct(val); isStruct {
+		if !interop {
+			return nil, false, fmt.Errorf("reading the fields of %T needs the interop capability", val)
+		}
+		name, isName := fieldName(key)
+		if !isName {
+			return nil, false, fmt.Errorf("field name must be a keyword, symbol or string: %s", Print(key))
+		}
+		f, exists := findField(s.Type(), name)
+		if !exists {
+			return nil, false, nil
+		}
+		field, err := s.FieldByIndexErr(f.index)
+		if err != nil {
+			// a field promoted through a nil embedded pointer
+			return nil, true, nil
+		}
+		return fromGo(field), true, nil
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Map {
+		k, err := toGo(key, v.Type().Key())
+		if err != nil {
+			return nil, false, err
+		}
+		ret := v.MapIndex(k)
+		if !ret.IsValid() {
+			return nil, false, nil
+		}
+		return fromGo(ret), true, nil
+	}
+
+	return nil, false, fmt.Errorf("trying to access nested value that isn't a map: %v", key)
+}
+
+// convert a go value into lisp data, with structs becoming maps with
+// keyword keys
+func toLisp(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Pointer && v.Elem().Kind() != reflect.Struct {
+			return v.Interface()
+		}
+		return toLisp(v.Elem())
+	case reflect.Struct:
+		fields := structFields(v.Type())
+		if len(fields) == 0 {
+			// opaque values like time.Time are left alone
+			return v.Interface()
+		}
+		ret := make(map[any]any, len(fields))
+		for _, f := range fields {
+			field, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				continue
+			}
+			ret[Keyword(f.name)] = toLisp(field)
+		}
+		return ret
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return List{}
+		}
+		ret := make(List, v.Len())
+		for i := range ret {
+			ret[i] = toLisp(v.Index(i))
+		}
+		return ret
+	case reflect.Map:
+		ret := make(map[any]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			ret[toLisp(iter.Key())] = toLisp(iter.Value())
+		}
+		return ret
+	}
+	return v.Interface()
+}
+
+// convert a struct into a map: (->map obj)
+func toMap(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to ->map", len(args))
+	}
+	if _, isStruct := asStruct(args[0]); !isStruct {
+		return nil, fmt.Errorf("can't convert %T to a map", args[0])
+	}
+	return toLisp(reflect.ValueOf(args[0])), nil
+}
+
+// build a new struct of the same type as a go value from a map:
+// (->struct obj {:field val})
+func toStruct(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of args (%d) passed to ->struct", len(args))
+	}
+	s, isStruct := asStruct(args[0])
+	if !isStruct {
+		return nil, fmt.Errorf("can't build a struct like %T", args[0])
+	}
+	if _, isMap := args[1].(map[any]any); !isMap {
+		return nil, fmt.Errorf("->struct expects a map: %s", Print(args[1]))
+	}
+
+	val, err := toGo(args[1], s.Type())
+	if err != nil {
+		return nil, err
+	}
+	ptr := reflect.New(s.Type())
+	ptr.Elem().Set(val)
+	return ptr.Interface(), nil
+}
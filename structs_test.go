@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStructAccess(t *testing.T) {
+	testEval(t, "((testconfig) :name)", "server")
+	testEval(t, "((testconfig) :port)", 8080)
+	testEval(t, "((testconfig) :Port)", 8080)
+	testEval(t, "((testconfig) :tls :cert)", "cert.pem")
+	testEval(t, "((testconfig) :hosts)", List{"a", "b"})
+	testEval(t, "((testconfig) :limits \"conns\")", 10)
+	testEval(t, "((testconfig) :Level)", "debug")
+	testEval(t, "((testpoint 1 2) :Y)", 2)
+	testEvalError(t, "((testconfig) :secret)")
+	testEvalError(t, "((testconfig) :missing)")
+	testEvalError(t, "((testconfig) :port :x)")
+	testEvalError(t, "((testconfig) 1)")
+}
+
+func TestStructToMap(t *testing.T) {
+	testEval(t, "(->map (testpoint 1 2))", map[any]any{
+		Keyword("X"): 1, Keyword("Y"): 2, Keyword("Tags"): List{"a", "b"},
+	})
+	testEval(t, "((->map (testconfig)) :tls)", map[any]any{
+		Keyword("cert"): "cert.pem", Keyword("key"): "",
+	})
+	testEval(t, "((->map (testconfig)) :Level)", "debug")
+	testEvalError(t, "(->map 1)")
+	testEvalError(t, "(->map)")
+}
+
+func TestMapToStruct(t *testing.T) {
+	testEval(t, "(.Sum (->struct (testpoint 0 0) {:X 3 :Y 4}))", 7)
+	testEval(t, `((->struct (testconfig) {:name "a" :tls {:cert "c"}}) :tls :cert)`, "c")
+	testEval(t, `((->struct (testconfig) {:Level "info"}) :Level)`, "info")
+	testEval(t, `(testconfigname {:name "b" :port 1})`, "b")
+	testEvalError(t, `(->struct (testconfig) {:secret "a"})`)
+	testEvalError(t, `(->struct (testconfig) {:port "a"})`)
+	testEvalError(t, `(->struct 1 {})`)
+	testEvalError(t, `(->struct (testconfig) [])`)
+}
+
+type testLogging struct {
+	Level string
+}
+
+type testConfig struct {
+	Name   string         `json:"name"`
+	Port   int            `json:"port,omitempty"`
+	Hosts  []string       `json:"hosts"`
+	Limits map[string]int `json:"limits"`
+	TLS    struct {
+		Cert string `json:"cert"`
+		Key  string `json:"key"`
+	} `json:"tls"`
+	Secret string `json:"-"`
+	testLogging
+}
+
+func init() {
+	defaultEnv[Symbol("testconfig")] = gofunc(func() testConfig {
+		c := testConfig{
+			Name:        "server",
+			Port:        8080,
+			Hosts:       []string{"a", "b"},
+			Limits:      map[string]int{"conns": 10},
+			Secret:      "hidden",
+			testLogging: testLogging{"debug"},
+		}
+		c.TLS.Cert = "cert.pem"
+		return c
+	})
+	defaultEnv[Symbol("testconfigname")] = gofunc(func(c *testConfig) string {
+		return c.Name
+	})
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+)
+
+// a function compiled to bytecode along with its captured variables
+type closure struct {
+	proto   *proto
+	upvals  []*upvalue
+	globals *Env
+}
+
+// a captured variable, which refers to a slot on the stack until the
+// frame that owns it returns
+type upvalue struct {
+	vm     *vm
+	slot   int
+	closed bool
+	value  any
+}
+
+func (u *upvalue) get() any {
+	if u.closed {
+		return u.value
+	}
+	return u.vm.stack[u.slot]
+}
+
+func (u *upvalue) set(val any) {
+	if u.closed {
+		u.value = val
+	} else {
+		u.vm.stack[u.slot] = val
+	}
+}
+
+type callFrame struct {
+	cl   *closure
+	ip   int
+	base int
+}
+
+type vm struct {
+	stack  []any
+	frames []callFrame
+	open   []*upvalue
+}
+
+// Evaluate an expression by compiling it to bytecode and running it
+func vmEval(val any, env *Env) (any, error) {
+	p, err := compileTop(val, env)
+	if err != nil {
+		return nil, err
+	}
+	return runClosure(&closure{proto: p, globals: env}, nil)
+}
+
+// run a closure to completion on a new vm
+func runClosure(cl *closure, args []any) (any, error) {
+	root := cl.globals.root()
+	if err := root.enter(); err != nil {
+		return nil, err
+	}
+	defer root.leave()
+
+	vm := &vm{stack: make([]any, 0, 64)}
+	vm.stack = append(vm.stack, cl)
+	vm.stack = append(vm.stack, args...)
+	if err := vm.enter(cl, 1, len(args)); err != nil {
+		return nil, err
+	}
+	return vm.run()
+}
+
+// push a frame for a closure whose args start at base
+func (vm *vm) enter(cl *closure, base, nargs int) error {
+	root := cl.globals.root()
+	if max := root.maxDepth(); root.depth+len(vm.frames) > max {
+		return &StackOverflowError{max}
+	}
+	if err := charge(root, 1, int64(len(cl.proto.localNames))*slotSize); err != nil {
+		return err
+	}
+	if cl.proto.variadic {
+		args, err := bindArgs(cl.proto.arity, true, vm.stack[base:base+nargs])
+		if err != nil {
+			return err
+		}
+		vm.stack = append(vm.stack[:base], args...)
+	} else if nargs != cl.proto.arity {
+		return fmt.Errorf("wrong number of args (%d) passed to procedure", nargs)
+	}
+	for i := len(vm.stack) - base; i < len(cl.proto.localNames); i++ {
+		vm.stack = append(vm.stack, unboundVar{})
+	}
+	vm.frames = append(vm.frames, callFrame{cl, 0, base})
+	return nil
+}
+
+func (vm *vm) run() (any, error) {
+	frame := &vm.frames[len(vm.frames)-1]
+	code := frame.cl.proto.code
+
+	for {
+		op := opcode(code[frame.ip])
+		frame.ip++
+
+		var arg int
+		if op != opPop && op != opReturn {
+			arg = int(code[frame.ip])<<8 | int(code[frame.ip+1])
+			frame.ip += 2
+		}
+
+		switch op {
+		case opConst:
+			vm.push(frame.cl.proto.consts[arg])
+
+		case opLocal:
+			val := vm.stack[frame.base+arg]
+			if _, isUnbound := val.(unboundVar); isUnbound {
+				return vm.fail(unresolved(frame.cl.proto.localNames[arg]))
+			}
+			vm.push(val)
+
+		case opSetLocal:
+			vm.stack[frame.base+arg] = vm.pop()
+
+		case opUpval:
+			val := frame.cl.upvals[arg].get()
+			if _, isUnbound := val.(unboundVar); isUnbound {
+				return vm.fail(unresolved(frame.cl.proto.upvalNames[arg]))
+			}
+			vm.push(val)
+
+		case opGlobal:
+			val, err := frame.cl.globals.Find(frame.cl.proto.consts[arg].(Symbol))
+			if err != nil {
+				return vm.fail(err)
+			}
+			vm.push(val)
+
+		case opDefGlobal:
+			sym := frame.cl.proto.consts[arg].(Symbol)
+			frame.cl.globals.Define(sym, vm.pop())
+			vm.push(sym)
+
+		case opPop:
+			vm.pop()
+
+		case opJump:
+			frame.ip += arg
+
+		case opJumpIfFalse:
+			if !isTruthy(vm.pop()) {
+				frame.ip += arg
+			}
+
+		case opElse:
+			if vm.stack[len(vm.stack)-1] == elsekw {
+				// skip over the jump that follows this instruction
+				vm.stack[frame.base+arg] = frame.ip + 3
+				vm.stack[len(vm.stack)-1] = false
+			}
+
+		case opJumpElse:
+			target, isSet := vm.stack[frame.base+arg].(int)
+			if isSet {
+				frame.ip = target
+			} else {
+				vm.push(nil)
+			}
+
+		case opCall:
+			arg, err := vm.expandTail(arg)
+			if err != nil {
+				return vm.fail(err)
+			}
+			calleeIdx := len(vm.stack) - arg - 1
+			cl, isClosure := vm.stack[calleeIdx].(*closure)
+			if isClosure {
+				if err := vm.enter(cl, calleeIdx+1, arg); err != nil {
+					return vm.fail(err)
+				}
+				frame = &vm.frames[len(vm.frames)-1]
+				code = frame.cl.proto.code
+				continue
+			}
+
+			res, err := vm.invokeTop(arg, frame.cl.globals.root().interop)
+			if err != nil {
+				return vm.fail(err)
+			}
+			vm.push(res)
+
+		case opTailCall:
+			arg, err := vm.expandTail(arg)
+			if err != nil {
+				return vm.fail(err)
+			}
+			calleeIdx := len(vm.stack) - arg - 1
+			cl, isClosure := vm.stack[calleeIdx].(*closure)
+			if isClosure {
+				// replace the current frame with the new call
+				vm.closeUpvals(frame.base)
+				copy(vm.stack[frame.base-1:], vm.stack[calleeIdx:])
+				vm.stack = vm.stack[:frame.base+arg]
+				vm.frames = vm.frames[:len(vm.frames)-1]
+				if err := vm.enter(cl, frame.base, arg); err != nil {
+					return vm.fail(err)
+				}
+				frame = &vm.frames[len(vm.frames)-1]
+				code = frame.cl.proto.code
+				continue
+			}
+
+			res, err := vm.invokeTop(arg, frame.cl.globals.root().interop)
+			if err != nil {
+				return vm.fail(err)
+			}
+			vm.push(res)
+			fallthrough
+
+		case opReturn:
+			res := vm.pop()
+			vm.closeUpvals(frame.base)
+			vm.stack = vm.stack[:frame.base-1]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				return res, nil
+			}
+			vm.push(res)
+			frame = &vm.frames[len(vm.frames)-1]
+			code = frame.cl.proto.code
+
+		case opClosure:
+			p := frame.cl.proto.consts[arg].(*proto)
+			cl := &closure{p, make([]*upvalue, len(p.upvals)), frame.cl.globals}
+			for i, desc := range p.upvals {
+				if desc.isLocal {
+					cl.upvals[i] = vm.capture(frame.base + desc.index)
+				} else {
+					cl.upvals[i] = frame.cl.upvals[desc.index]
+				}
+			}
+			vm.push(cl)
+
+		case opVector:
+			if err := charge(frame.cl.globals, 0, int64(arg)*slotSize); err != nil {
+				return vm.fail(err)
+			}
+			vect := make([]any, arg)
+			copy(vect, vm.stack[len(vm.stack)-arg:])
+			vm.stack = vm.stack[:len(vm.stack)-arg]
+			vm.push(vect)
+
+		case opMap:
+			if err := charge(frame.cl.globals, 0, int64(2*arg)*slotSize); err != nil {
+				return vm.fail(err)
+			}
+			m, err := buildMap(vm.stack[len(vm.stack)-2*arg:])
+			if err != nil {
+				return vm.fail(err)
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*arg]
+			vm.push(m)
+
+		case opSet:
+			if err := charge(frame.cl.globals, 0, int64(arg)*slotSize); err != nil {
+				return vm.fail(err)
+			}
+			set, err := buildSet(vm.stack[len(vm.stack)-arg:])
+			if err != nil {
+				return vm.fail(err)
+			}
+			vm.stack = vm.stack[:len(vm.stack)-arg]
+			vm.push(set)
+
+		case opSpecial:
+			// locals reserved by def are passed along even if they aren't
+			// bound yet, since the special form might be what binds them
+			spec := frame.cl.proto.consts[arg].(*specialCall)
+			vals := make([]any, len(spec.vars))
+			for i, v := range spec.vars {
+				if v.isUpval {
+					vals[i] = frame.cl.upvals[v.index].get()
+				} else {
+					vals[i] = vm.stack[frame.base+v.index]
+				}
+			}
+			env := frameEnv(frame.cl.globals, vals, spec.size)
+			res, err := spec.body(env)
+			if err != nil {
+				return vm.fail(err)
+			}
+			for i, v := range spec.vars {
+				if v.isUpval {
+					frame.cl.upvals[v.index].set(env.slots[i])
+				} else {
+					vm.stack[frame.base+v.index] = env.slots[i]
+				}
+			}
+			vm.push(res)
+
+		case opFolded:
+			folded := frame.cl.proto.consts[arg].(*foldedConst)
+			if foldValid(frame.cl.globals, folded.deps) {
+				vm.push(folded.val)
+			} else {
+				frame.ip += 3
+			}
+
+		case opRecur:
+			target := frame.cl.proto.consts[arg].(*recurTarget)
+			if err := charge(frame.cl.globals, 1, 0); err != nil {
+				return vm.fail(err)
+			}
+			// closures from the last pass keep the values they captured
+			from := frame.base + target.first
+			vm.closeUpvals(from)
+			copy(vm.stack[from:], vm.stack[len(vm.stack)-target.n:])
+			vm.stack = vm.stack[:len(vm.stack)-target.n]
+			frame.ip = target.start
+
+		default:
+			return vm.fail(fmt.Errorf("invalid opcode: %d", op))
+		}
+	}
+}
+
+// call a function that isn't a closure with the top nargs values on the
+// stack, removing the function and args from the stack
+func (vm *vm) invokeTop(nargs int, interop bool) (any, error) {
+	calleeIdx := len(vm.stack) - nargs - 1
+	args := make([]any, nargs)
+	copy(args, vm.stack[calleeIdx+1:])
+	callee := vm.stack[calleeIdx]
+	vm.stack = vm.stack[:calleeIdx]
+	return invokeIn(callee, args, interop)
+}
+
+// replace a call to a tail primitive at the top of the stack with the call
+// that it makes, returning the new number of args
+func (vm *vm) expandTail(nargs int) (int, error) {
+	for {
+		calleeIdx := len(vm.stack) - nargs - 1
+		tp, isTailPrim := vm.stack[calleeIdx].(tailPrimitive)
+		if !isTailPrim {
+			return nargs, nil
+		}
+
+		args := make([]any, nargs)
+		copy(args, vm.stack[calleeIdx+1:])
+		next, nextArgs, err := tp(args)
+		if err != nil {
+			return 0, err
+		}
+		vm.stack = append(vm.stack[:calleeIdx], next)
+		vm.stack = append(vm.stack, nextArgs...)
+		nargs = len(nextArgs)
+	}
+}
+
+func (vm *vm) push(val any) {
+	vm.stack = append(vm.stack, val)
+}
+
+func (vm *vm) pop() any {
+	val := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return val
+}
+
+// find or create the upvalue that refers to a stack slot
+func (vm *vm) capture(slot int) *upvalue {
+	for _, u := range vm.open {
+		if u.slot == slot {
+			return u
+		}
+	}
+	u := &upvalue{vm: vm, slot: slot}
+	vm.open = append(vm.open, u)
+	return u
+}
+
+// move the values of upvalues at or above a stack slot off of the stack
+func (vm *vm) closeUpvals(from int) {
+	open := vm.open[:0]
+	for _, u := range vm.open {
+		if u.slot >= from {
+			u.value = vm.stack[u.slot]
+			u.closed = true
+		} else {
+			open = append(open, u)
+		}
+	}
+	vm.open = open
+}
+
+// abandon the run, making sure that any closures that escaped still work
+func (vm *vm) fail(err error) (any, error) {
+	vm.closeUpvals(0)
+	return nil, err
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// run every test against both the analyzing interpreter and the vm
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if code == 0 {
+		useVM = true
+		code = m.Run()
+	}
+	os.Exit(code)
+}
+
+func TestEngine(t *testing.T) {
+	val, err := readEval("(fn [x] x)", NewEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, isClosure := val.(*closure)
+	if isClosure != useVM {
+		t.Errorf("expected closure: %v, actual: %T", useVM, val)
+	}
+}
+
+func TestCapturedDefs(t *testing.T) {
+	testEval(t, `
+		(defn f []
+			(def g (fn [] x))
+			(def x 5)
+			(g))
+		(f)`, 5)
+	testEval(t, `
+		(defn make-counter [start]
+			(def step 3)
+			(fn [n] (+ start (* n step))))
+		((make-counter 10) 2)`, 16)
+	testEval(t, `
+		(defn outer [a]
+			(fn [b]
+				(fn [c] [a b c])))
+		(((outer 1) 2) 3)`, []any{1, 2, 3})
+	testEvalError(t, `
+		(defn f [] (if false (def y 1)) y)
+		(f)`)
+}
+
+func TestCondElse(t *testing.T) {
+	testEval(t, "(cond :else 1 true 2)", 2)
+	testEval(t, "(cond false 1 :else 2)", 2)
+	testEval(t, "(cond false 1 false 2)", nil)
+	testEval(t, "(def e :else) (cond e 1 nil 2)", 1)
+	testEval(t, "((fn [x] (cond (= x 1) :one :else :other)) 2)", Keyword("other"))
+}
+
+func TestSpecialFormWithoutBytecode(t *testing.T) {
+	testEval(t, "(testspecial 1 2)", 2)
+	testEval(t, `
+		(defn f [x]
+			(def y 10)
+			((fn [z] (testspecial (+ x y z))) 100))
+		(f 1)`, 111)
+
+	// locals reserved by def that aren't bound yet
+	testEval(t, "((fn [] (var +) (def q 1) q))", 1)
+	testEval(t, "((fn [] (defmulti mm :k) (def q 1) q))", 1)
+	testEval(t, "((fn [] (defrecord R [a]) (def q 1) q))", 1)
+	testEval(t, "((fn [] (defprotocol PP (pp [t])) (def q 1) q))", 1)
+	testEval(t, "((fn [] (testspecial (def q 1)) q))", 1)
+	testEval(t, "((fn [] (def q 1) ((fn [] (testspecial q)))))", 1)
+}
+
+func init() {
+	defaultEnv[Symbol("testspecial")] = specialform(testspecial)
+}
+
+func testspecial(args []any, sc *scope, tail bool) (compiled, error) {
+	return analyzeBody(args, sc, false)
+}